@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	mp3 "github.com/hajimehoshi/go-mp3"
+	"github.com/oov/audio/resampler"
+)
+
+// audioDecoder yields interleaved PCM samples scaled to [-1, 1].
+type audioDecoder interface {
+	SampleRate() int
+	Channels() int
+	// Read fills buf with interleaved samples and returns how many were
+	// written, always a multiple of Channels. It returns io.EOF at the end
+	// of the stream, possibly together with the last samples.
+	Read(buf []float32) (int, error)
+}
+
+// convertToSamples decodes a whole stream into 16 kHz mono samples. r does
+// not need to be seekable.
+func convertToSamples(r io.Reader) ([]float32, error) {
+	d, err := newAudioDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+
+	pcm, err := newPCMReader(d, channelMix)
+	if err != nil {
+		return nil, err
+	}
+	var samples []float32
+	buf := [][]float32{make([]float32, 16384)}
+	for {
+		n, err := pcm.Read(buf)
+		samples = append(samples, buf[0][:n]...)
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// channelMode selects which input channels become tracks.
+type channelMode int
+
+const (
+	channelMix      channelMode = iota // average all channels into one track
+	channelLeft                        // first channel only
+	channelRight                       // second channel only
+	channelSeparate                    // one track per channel
+)
+
+func parseChannelMode(s string) (channelMode, error) {
+	switch strings.ToLower(s) {
+	case "mix", "":
+		return channelMix, nil
+	case "left":
+		return channelLeft, nil
+	case "right":
+		return channelRight, nil
+	case "separate":
+		return channelSeparate, nil
+	}
+	return 0, fmt.Errorf("unknown channel mode %q (want mix, left, right or separate)", s)
+}
+
+// pcmReader turns a decoder's output into one or more 16 kHz mono tracks,
+// one block at a time, so memory use does not depend on the input length.
+// Tracks are selected by a channelMode.
+type pcmReader struct {
+	d       audioDecoder
+	pick    []int                // source channel per track; nil means mix all
+	rs      *resampler.Resampler // nil when the source is already 16 kHz
+	raw     []float32            // interleaved decoder output
+	mono    [][]float32          // per-track samples not yet resampled
+	block   [][]float32          // backing arrays for mono
+	err     error                // sticky decoder error, reported once mono drains
+	total   int                  // samples returned per track so far
+	flushed bool                 // the resampler tail has been queued
+}
+
+func newPCMReader(d audioDecoder, mode channelMode) (*pcmReader, error) {
+	const blockFrames = 4096
+	channels := d.Channels()
+	r := &pcmReader{
+		d:   d,
+		raw: make([]float32, blockFrames*channels),
+	}
+
+	switch mode {
+	case channelLeft:
+		r.pick = []int{0}
+	case channelRight:
+		if channels < 2 {
+			return nil, fmt.Errorf("right channel requested but input has %d channel", channels)
+		}
+		r.pick = []int{1}
+	case channelSeparate:
+		r.pick = make([]int, channels)
+		for c := range r.pick {
+			r.pick[c] = c
+		}
+	}
+
+	tracks := r.Tracks()
+	r.mono = make([][]float32, tracks)
+	r.block = make([][]float32, tracks)
+	for t := range r.block {
+		r.block[t] = make([]float32, blockFrames)
+	}
+	if d.SampleRate() != sampleRate {
+		// Skip the filter's leading zeros so output stays aligned with the
+		// input; Read feeds the same latency back in as silence at the end.
+		r.rs = resampler.NewWithSkipZeros(tracks, d.SampleRate(), sampleRate, 4)
+	}
+	return r, nil
+}
+
+// Tracks returns the number of tracks Read fills.
+func (r *pcmReader) Tracks() int {
+	if r.pick == nil {
+		return 1
+	}
+	return len(r.pick)
+}
+
+// Duration returns the length of the audio read so far.
+func (r *pcmReader) Duration() float64 {
+	return float64(r.total) / sampleRate
+}
+
+// Read fills one buffer per track with the same number of samples.
+func (r *pcmReader) Read(out [][]float32) (int, error) {
+	for {
+		if len(r.mono[0]) == 0 {
+			if r.err == io.EOF && r.rs != nil && !r.flushed {
+				r.flush()
+				continue
+			}
+			if r.err != nil {
+				return 0, r.err
+			}
+			r.fill()
+			continue
+		}
+		var n int
+		for t := range r.mono {
+			if r.rs == nil {
+				n = copy(out[t], r.mono[t])
+				r.mono[t] = r.mono[t][n:]
+			} else {
+				var read int
+				read, n = r.rs.ProcessFloat32(t, r.mono[t], out[t])
+				r.mono[t] = r.mono[t][read:]
+			}
+		}
+		if n > 0 {
+			r.total += n
+			return n, nil
+		}
+	}
+}
+
+// flush queues silence on every track to push the last input samples still
+// held in the resampler's filter out before Read reports io.EOF.
+func (r *pcmReader) flush() {
+	r.flushed = true
+	zeros := make([]float32, r.rs.InputLatency())
+	for t := range r.mono {
+		r.mono[t] = zeros
+	}
+}
+
+// fill decodes the next block and splits or downmixes it into tracks.
+func (r *pcmReader) fill() {
+	channels := r.d.Channels()
+	n, err := r.d.Read(r.raw)
+	frames := n / channels
+	for t := range r.mono {
+		mono := r.block[t][:frames]
+		if r.pick == nil {
+			for i := range mono {
+				var sum float32
+				for c := 0; c < channels; c++ {
+					sum += r.raw[i*channels+c]
+				}
+				mono[i] = sum / float32(channels)
+			}
+		} else {
+			src := r.pick[t]
+			for i := range mono {
+				mono[i] = r.raw[i*channels+src]
+			}
+		}
+		r.mono[t] = mono
+	}
+	if err == io.EOF {
+		r.err = io.EOF
+	} else if err != nil {
+		r.err = fmt.Errorf("read pcm: %w", err)
+	} else if n == 0 {
+		r.err = io.ErrNoProgress
+	}
+}
+
+// trackNames labels the tracks of a pcmReader for output. Stereo files in
+// separate mode get "left" and "right"; names overrides the defaults.
+func trackNames(mode channelMode, tracks int, names []string) []string {
+	out := make([]string, tracks)
+	for t := range out {
+		switch {
+		case t < len(names) && names[t] != "":
+			out[t] = names[t]
+		case mode != channelSeparate:
+			out[t] = ""
+		case tracks == 2 && t == 0:
+			out[t] = "left"
+		case tracks == 2 && t == 1:
+			out[t] = "right"
+		default:
+			out[t] = fmt.Sprintf("ch%d", t+1)
+		}
+	}
+	return out
+}
+
+// newAudioDecoder picks a decoder by sniffing the stream header, so the file
+// extension does not matter. The header is peeked through a bufio.Reader,
+// which keeps non-seekable sources such as pipes working. Anything
+// unrecognised is handed to the MP3 decoder, which skips ID3 tags and syncs
+// to the first frame on its own.
+func newAudioDecoder(r io.Reader) (audioDecoder, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(12)
+
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		d, err := newWavDecoder(br)
+		if err != nil {
+			return nil, fmt.Errorf("decode wav: %w", err)
+		}
+		return d, nil
+	case len(head) >= 4 && bytes.Equal(head[0:4], []byte("fLaC")):
+		d, err := newFlacDecoder(br)
+		if err != nil {
+			return nil, fmt.Errorf("decode flac: %w", err)
+		}
+		return d, nil
+	case len(head) >= 4 && bytes.Equal(head[0:4], []byte("OggS")):
+		d, err := newOggDecoder(br)
+		if err != nil {
+			return nil, fmt.Errorf("decode ogg: %w", err)
+		}
+		return d, nil
+	default:
+		d, err := newMP3Decoder(br)
+		if err != nil {
+			return nil, fmt.Errorf("decode mp3: %w", err)
+		}
+		return d, nil
+	}
+}
+
+// mp3Decoder adapts go-mp3, which always outputs stereo int16 LE.
+type mp3Decoder struct {
+	d   *mp3.Decoder
+	raw []byte
+}
+
+func newMP3Decoder(r io.Reader) (*mp3Decoder, error) {
+	d, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	return &mp3Decoder{d: d}, nil
+}
+
+func (m *mp3Decoder) SampleRate() int { return m.d.SampleRate() }
+func (m *mp3Decoder) Channels() int   { return 2 }
+
+func (m *mp3Decoder) Read(buf []float32) (int, error) {
+	// Each frame is 4 bytes [L_lo, L_hi, R_lo, R_hi]
+	frames := len(buf) / 2
+	if cap(m.raw) < frames*4 {
+		m.raw = make([]byte, frames*4)
+	}
+	raw := m.raw[:frames*4]
+	n, err := io.ReadFull(m.d, raw)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	frames = n / 4
+	for i := 0; i < frames; i++ {
+		buf[2*i] = float32(int16(binary.LittleEndian.Uint16(raw[i*4:]))) / 32768.0
+		buf[2*i+1] = float32(int16(binary.LittleEndian.Uint16(raw[i*4+2:]))) / 32768.0
+	}
+	return frames * 2, err
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"testing"
+)
+
+// TestConvertToSamplesPipe feeds every container through an io.Pipe, which
+// can neither seek nor return more than one write per read, as when audio
+// arrives on stdin.
+func TestConvertToSamplesPipe(t *testing.T) {
+	wav := makeWAV(wavFormatPCM, 2, 16000, 16, false, []float64{0.5, 0.5, -0.5, -0.5, 0.25, 0.25})
+	flac := makeFLAC(16000, 16, 16, [][]int32{{16384, -16384, 8192}})
+
+	opusHead := append([]byte("OpusHead\x01\x01"), 0, 0)         // mono, no pre-skip
+	opusHead = binary.LittleEndian.AppendUint32(opusHead, 16000) // input rate
+	opusHead = append(opusHead, 0, 0, 0)                         // gain, mapping family
+	var ogg bytes.Buffer
+	ogg.Write(oggPage(1, 0, 0x02, 0, oggLacing(len(opusHead)), opusHead))
+	ogg.Write(oggPage(1, 1, 0, 0, oggLacing(8), []byte("OpusTags")))
+	ogg.Write(oggPage(1, 2, 0x04, 960, []byte{1}, []byte{0xF8}))
+
+	// short.ogg is one second of 44.1 kHz mono Vorbis
+	vorbis, err := os.ReadFile("testdata/short.ogg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mp3, err := os.ReadFile("testdata/short.mp3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reading the MP3 from memory gives the reference length
+	mp3Samples, err := convertToSamples(bytes.NewReader(mp3))
+	if err != nil {
+		t.Fatalf("decode mp3: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"wav", wav, 3},
+		{"flac", flac, 3},
+		{"ogg opus", ogg.Bytes(), 320},
+		{"ogg vorbis", vorbis, 16000},
+		{"mp3", mp3, len(mp3Samples)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "ogg opus" && !opusBuilt {
+				t.Skip("built without Opus support")
+			}
+			pr, pw := io.Pipe()
+			defer pr.Close()
+			go func() {
+				// Dribble the stream out in small writes
+				data := tt.data
+				for len(data) > 0 {
+					n := min(len(data), 7)
+					pw.Write(data[:n])
+					data = data[n:]
+				}
+				pw.Close()
+			}()
+
+			got, err := convertToSamples(pr)
+			if err != nil {
+				t.Fatalf("convertToSamples: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %d samples, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestPCMReaderChannelModes(t *testing.T) {
+	// Three stereo frames at 16 kHz, L and R clearly distinct
+	wav := makeWAV(wavFormatFloat, 2, 16000, 32, false, []float64{0.5, -0.5, 0.25, 0.75, 1, 0})
+
+	tests := []struct {
+		mode channelMode
+		want [][]float32
+	}{
+		{channelMix, [][]float32{{0, 0.5, 0.5}}},
+		{channelLeft, [][]float32{{0.5, 0.25, 1}}},
+		{channelRight, [][]float32{{-0.5, 0.75, 0}}},
+		{channelSeparate, [][]float32{{0.5, 0.25, 1}, {-0.5, 0.75, 0}}},
+	}
+	for _, tt := range tests {
+		d, err := newAudioDecoder(bytes.NewReader(wav))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := newPCMReader(d, tt.mode)
+		if err != nil {
+			t.Fatalf("mode %d: %v", tt.mode, err)
+		}
+		if r.Tracks() != len(tt.want) {
+			t.Fatalf("mode %d: got %d tracks, want %d", tt.mode, r.Tracks(), len(tt.want))
+		}
+		bufs := make([][]float32, r.Tracks())
+		for i := range bufs {
+			bufs[i] = make([]float32, 16)
+		}
+		n, err := r.Read(bufs)
+		if err != nil {
+			t.Fatalf("mode %d: Read: %v", tt.mode, err)
+		}
+		for tr, want := range tt.want {
+			got := bufs[tr][:n]
+			if len(got) != len(want) {
+				t.Fatalf("mode %d track %d: got %v, want %v", tt.mode, tr, got, want)
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("mode %d track %d: got %v, want %v", tt.mode, tr, got, want)
+					break
+				}
+			}
+		}
+	}
+
+	// Right channel of a mono file is an error
+	mono := makeWAV(wavFormatPCM, 1, 16000, 16, false, []float64{0})
+	d, err := newAudioDecoder(bytes.NewReader(mono))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newPCMReader(d, channelRight); err == nil {
+		t.Error("expected error selecting the right channel of a mono file")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// segmentScores are whisper's confidence measures for one segment, the same
+// values the hallucination filter judges it by, so reviewers can triage
+// spans that passed the filter but only just.
+type segmentScores struct {
+	AvgLogprob       float64 `json:"avg_logprob"`
+	NoSpeechProb     float64 `json:"no_speech_prob"`
+	CompressionRatio float64 `json:"compression_ratio"`
+	MinTokenProb     float64 `json:"min_token_prob"` // lowest of the text tokens; 0 without any
+}
+
+// scoreSegment measures segment, with the compression metric of cfg.
+// isText tells text tokens from special ones, whose probabilities say
+// nothing about the words.
+func scoreSegment(segment whisper.Segment, isText func(whisper.Token) bool, cfg filterConfig) segmentScores {
+	minProb := math.Inf(1)
+	for _, tok := range segment.Tokens {
+		if isText(tok) {
+			minProb = min(minProb, float64(tok.P))
+		}
+	}
+	if math.IsInf(minProb, 1) {
+		minProb = 0
+	}
+	return segmentScores{
+		AvgLogprob:       roundScore(avgLogprob(segment)),
+		NoSpeechProb:     roundScore(float64(segment.NoSpeechProb)),
+		CompressionRatio: roundScore(cfg.compressionRatio(strings.TrimSpace(segment.Text))),
+		MinTokenProb:     roundScore(minProb),
+	}
+}
+
+// summary formats the scores for the text formats.
+func (s segmentScores) summary() string {
+	return fmt.Sprintf("logprob %.2f, no-speech %.2f, compression %.2f, min-p %.2f",
+		s.AvgLogprob, s.NoSpeechProb, s.CompressionRatio, s.MinTokenProb)
+}
+
+// roundScore keeps four decimals, plenty to compare against thresholds
+// without JSON showing float32 noise.
+func roundScore(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
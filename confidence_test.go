@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+func TestScoreSegment(t *testing.T) {
+	isText := (&fakeContext{}).IsText
+	segment := whisper.Segment{
+		Text:         " Hello there.",
+		NoSpeechProb: 0.125,
+		Tokens: []whisper.Token{
+			{Id: 50365, Text: "[_TT_0]", P: 0.01},
+			{Id: 1, Text: " Hello", P: 0.9},
+			{Id: 2, Text: " there", P: 0.5},
+			{Id: 3, Text: ".", P: 0.8},
+		},
+	}
+
+	got := scoreSegment(segment, isText, defaultFilterConfig())
+	want := segmentScores{
+		AvgLogprob:       roundScore(avgLogprob(segment)),
+		NoSpeechProb:     0.125,
+		CompressionRatio: roundScore(compressionRatio("Hello there.")),
+		MinTokenProb:     0.5, // the special token's 0.01 does not count
+	}
+	if got != want {
+		t.Errorf("scoreSegment() = %+v, want %+v", got, want)
+	}
+
+	t.Run("no text tokens", func(t *testing.T) {
+		got := scoreSegment(whisper.Segment{Tokens: []whisper.Token{{Id: 50365, P: 0.3}}}, isText, defaultFilterConfig())
+		if got.MinTokenProb != 0 {
+			t.Errorf("MinTokenProb = %v, want 0", got.MinTokenProb)
+		}
+	})
+}
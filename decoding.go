@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// decodingOptions select whisper's decoding strategy for every chunk.
+type decodingOptions struct {
+	BeamSize       int     // beams searched; 1 decodes greedily
+	BestOf         int     // candidates sampled at each temperature above zero (greedy only)
+	Temperature    float32 // sampling temperature of the first attempt
+	TemperatureInc float32 // step by which a failed decode is retried hotter; 0 disables fallback
+}
+
+// decodingPresets are the named starting points for -decoding. speed is
+// what the tool has always done; accuracy follows the reference whisper
+// settings, for hard audio, at several times the cost.
+var decodingPresets = map[string]decodingOptions{
+	"speed":    {BeamSize: 1, BestOf: 1, Temperature: 0, TemperatureInc: 0},
+	"accuracy": {BeamSize: 5, BestOf: 5, Temperature: 0, TemperatureInc: 0.2},
+}
+
+func decodingPreset(name string) (decodingOptions, error) {
+	d, ok := decodingPresets[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(decodingPresets))
+		for n := range decodingPresets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return d, fmt.Errorf("unknown decoding preset %q (want %s)", name, strings.Join(names, " or "))
+	}
+	return d, nil
+}
+
+func (d decodingOptions) validate() error {
+	switch {
+	case d.BeamSize < 1:
+		return fmt.Errorf("beam size must be at least 1, got %d", d.BeamSize)
+	case d.BestOf < 1:
+		return fmt.Errorf("best-of must be at least 1, got %d", d.BestOf)
+	case d.Temperature < 0 || d.Temperature > 1:
+		return fmt.Errorf("temperature must be between 0 and 1, got %g", d.Temperature)
+	case d.TemperatureInc < 0 || d.TemperatureInc > 1:
+		return fmt.Errorf("temperature increment must be between 0 and 1, got %g", d.TemperatureInc)
+	}
+	return nil
+}
+
+// strategySetter is implemented by contexts of the patched binding (see
+// patches/whisper-bindings-decoding.patch). Upstream, SetBeamSize only
+// stores the size while decoding stays greedy, and best-of cannot be set.
+type strategySetter interface {
+	SetBeamSearch(beamSize int)
+	SetBestOf(n int)
+}
+
+// apply configures ctx to decode with d.
+func (d decodingOptions) apply(ctx whisper.Context) error {
+	s, ok := ctx.(strategySetter)
+	if !ok && (d.BeamSize > 1 || d.BestOf > 1) {
+		return fmt.Errorf("beam search and best-of need the patched whisper bindings; run make patch")
+	}
+	ctx.SetBeamSize(d.BeamSize)
+	ctx.SetTemperature(d.Temperature)
+	// whisper.cpp only falls back when the increment is positive
+	ctx.SetTemperatureFallback(d.TemperatureInc)
+	if ok {
+		s.SetBeamSearch(d.BeamSize)
+		s.SetBestOf(d.BestOf)
+	}
+	return nil
+}
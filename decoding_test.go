@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// decodingContext records the decoding settings applied to it, like a
+// context of the unpatched binding.
+type decodingContext struct {
+	whisper.Context
+	beamSize    int
+	temperature float32
+	fallback    float32
+}
+
+func (c *decodingContext) SetBeamSize(n int)                { c.beamSize = n }
+func (c *decodingContext) SetTemperature(t float32)         { c.temperature = t }
+func (c *decodingContext) SetTemperatureFallback(t float32) { c.fallback = t }
+
+// patchedContext adds the setters of patches/whisper-bindings-decoding.patch.
+type patchedContext struct {
+	decodingContext
+	beamSearch int
+	bestOf     int
+}
+
+func (c *patchedContext) SetBeamSearch(n int) { c.beamSearch = n }
+func (c *patchedContext) SetBestOf(n int)     { c.bestOf = n }
+
+func TestDecodingPresets(t *testing.T) {
+	for name, d := range decodingPresets {
+		if err := d.validate(); err != nil {
+			t.Errorf("preset %s invalid: %v", name, err)
+		}
+	}
+	if d, err := decodingPreset("Accuracy"); err != nil || d.BeamSize != 5 || d.TemperatureInc != 0.2 {
+		t.Errorf("accuracy preset = %+v, %v", d, err)
+	}
+	if _, err := decodingPreset("quality"); err == nil {
+		t.Error("expected error for unknown preset")
+	}
+
+	bad := []decodingOptions{
+		{BeamSize: 0, BestOf: 1},
+		{BeamSize: 1, BestOf: 0},
+		{BeamSize: 1, BestOf: 1, Temperature: 1.5},
+		{BeamSize: 1, BestOf: 1, TemperatureInc: -0.2},
+	}
+	for _, d := range bad {
+		if err := d.validate(); err == nil {
+			t.Errorf("expected error for %+v", d)
+		}
+	}
+}
+
+func TestDecodingApply(t *testing.T) {
+	accuracy := decodingPresets["accuracy"]
+
+	// The speed preset works with the upstream binding
+	ctx := &decodingContext{}
+	if err := decodingPresets["speed"].apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.beamSize != 1 || ctx.temperature != 0 || ctx.fallback != 0 {
+		t.Errorf("speed applied as %+v", ctx)
+	}
+	// Beam search does not: it would silently decode greedily
+	if err := accuracy.apply(&decodingContext{}); err == nil {
+		t.Error("expected error applying beam search to an unpatched context")
+	}
+
+	patched := &patchedContext{}
+	if err := accuracy.apply(patched); err != nil {
+		t.Fatal(err)
+	}
+	if patched.beamSearch != 5 || patched.bestOf != 5 || patched.fallback != 0.2 {
+		t.Errorf("accuracy applied as %+v", patched)
+	}
+}
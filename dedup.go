@@ -9,6 +9,9 @@ import (
 // It filters:
 //   - segments with identical text and overlapping time ranges (keep the longer one)
 //   - segments fully contained within a longer segment with different text (keep the longer one)
+//
+// Only segments of the same speaker are compared, since separate channels
+// legitimately overlap in time.
 func deduplicateSegments(segments []transcriptSegment) []transcriptSegment {
 	if len(segments) <= 1 {
 		return segments
@@ -30,6 +33,9 @@ func deduplicateSegments(segments []transcriptSegment) []transcriptSegment {
 		skip := false
 
 		for i, prev := range result {
+			if prev.Speaker != seg.Speaker {
+				continue
+			}
 			prevStart := parseDuration(prev.Start)
 			prevEnd := parseDuration(prev.End)
 			prevDur := prevEnd - prevStart
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// filterRules are the hallucination filter's rules, in the order
+// filterSegment checks them, then the loop rules.
+var filterRules = []string{
+	ruleNoSpeech, ruleMinChars, ruleRealWords, rulePhrase, rulePrefix,
+	rulePattern, ruleRepeated, ruleLogprob, ruleCompression,
+	ruleWordLoop, ruleSegmentLoop,
+}
+
+// filterConfig holds the hallucination filter's thresholds, the rules
+// turned off and the phrase lists to load. It is read from -filter-config,
+// a JSON file whose keys override the defaults, e.g.
+//
+//	{"min_real_words": 0, "disable": ["known_phrase"], "lists": ["jingles.txt"]}
+type filterConfig struct {
+	NoSpeechProb   float64  `json:"no_speech_prob"`     // drop above
+	AvgLogprob     float64  `json:"avg_logprob"`        // drop below
+	Compression    float64  `json:"compression_ratio"`  // drop above
+	Metric         string   `json:"compression_metric"` // a compressionMetrics key
+	MinChars       int      `json:"min_chars"`          // drop shorter segments
+	MinRealWords   int      `json:"min_real_words"`     // drop segments with fewer
+	Disable        []string `json:"disable"`            // rule names, or "all"
+	Lists          []string `json:"lists"`              // phrase list files, see phraseList.load
+	ReplaceBuiltin bool     `json:"replace_builtin"`    // use only Lists, not the compiled-in list
+	Stopwords      []string `json:"stopwords"`          // stopword files, see stopwordSets.load
+
+	phrases   *phraseList  // built by loadLists; nil is the compiled-in list
+	stopwords stopwordSets // built by loadLists; nil is the compiled-in sets
+}
+
+func defaultFilterConfig() filterConfig {
+	return filterConfig{
+		NoSpeechProb: noSpeechProbThreshold,
+		AvgLogprob:   avgLogprobThreshold,
+		Compression:  compressionThreshold,
+		Metric:       "zlib",
+		MinChars:     minSegmentChars,
+		MinRealWords: minRealWords,
+	}
+}
+
+// compressionMetrics are the ways to measure a segment's compression ratio.
+var compressionMetrics = map[string]func(string) float64{
+	"zlib":   compressionRatio,
+	"bigram": bigramRatio,
+}
+
+// compressionRatio measures text with the configured metric.
+func (c filterConfig) compressionRatio(text string) float64 {
+	return compressionMetrics[c.Metric](text)
+}
+
+// loadFilterConfig reads a config file over the defaults. Unknown keys are
+// an error, so a misspelt threshold is not silently ignored.
+func loadFilterConfig(path string) (filterConfig, error) {
+	c := defaultFilterConfig()
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return c, fmt.Errorf("parse %s: %w", path, err)
+	}
+	// Lists are found next to the config file
+	for _, files := range [][]string{c.Lists, c.Stopwords} {
+		for i, list := range files {
+			if !filepath.IsAbs(list) {
+				files[i] = filepath.Join(filepath.Dir(path), list)
+			}
+		}
+	}
+	return c, nil
+}
+
+// loadLists builds the phrase list from the compiled-in list, unless
+// ReplaceBuiltin is set, and the files in Lists, and adds the files in
+// Stopwords to the built-in stopwords.
+func (c *filterConfig) loadLists() error {
+	c.phrases, c.stopwords = nil, nil
+	if len(c.Lists) > 0 || c.ReplaceBuiltin {
+		l := newPhraseList()
+		if !c.ReplaceBuiltin {
+			l = newBuiltinPhraseList()
+		}
+		for _, path := range c.Lists {
+			if err := l.load(path); err != nil {
+				return err
+			}
+		}
+		c.phrases = l
+	}
+	if len(c.Stopwords) > 0 {
+		s := newBuiltinStopwords()
+		for _, path := range c.Stopwords {
+			if err := s.load(path); err != nil {
+				return err
+			}
+		}
+		c.stopwords = s
+	}
+	return nil
+}
+
+// stopwordSets returns the stopwords the real words rule ignores.
+func (c filterConfig) stopwordSets() stopwordSets {
+	if c.stopwords == nil {
+		return builtinStopwords
+	}
+	return c.stopwords
+}
+
+// phraseList returns the list the phrase, prefix and pattern rules match
+// against.
+func (c filterConfig) phraseList() *phraseList {
+	if c.phrases == nil {
+		return builtinPhrases
+	}
+	return c.phrases
+}
+
+// parseRuleList splits a comma-separated list of rule names.
+func parseRuleList(s string) []string {
+	var rules []string
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			rules = append(rules, strings.ToLower(r))
+		}
+	}
+	return rules
+}
+
+func (c filterConfig) validate() error {
+	switch {
+	case c.NoSpeechProb < 0 || c.NoSpeechProb > 1:
+		return fmt.Errorf("no-speech threshold must be between 0 and 1, got %g", c.NoSpeechProb)
+	case c.AvgLogprob > 0:
+		return fmt.Errorf("logprob threshold must not be positive, got %g", c.AvgLogprob)
+	case c.Compression <= 0:
+		return fmt.Errorf("compression ratio threshold must be positive, got %g", c.Compression)
+	case compressionMetrics[c.Metric] == nil:
+		return fmt.Errorf("unknown compression metric %q (want zlib or bigram)", c.Metric)
+	case c.MinChars < 0:
+		return fmt.Errorf("minimum characters must not be negative, got %d", c.MinChars)
+	case c.MinRealWords < 0:
+		return fmt.Errorf("minimum real words must not be negative, got %d", c.MinRealWords)
+	}
+	for _, r := range c.Disable {
+		if r != "all" && !slices.Contains(filterRules, r) {
+			return fmt.Errorf("unknown filter rule %q (want all or one of %s)", r, strings.Join(filterRules, ", "))
+		}
+	}
+	return nil
+}
+
+// enabled reports whether rule is checked.
+func (c filterConfig) enabled(rule string) bool {
+	return !slices.Contains(c.Disable, rule) && !slices.Contains(c.Disable, "all")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+func TestFilterConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("file overrides defaults", func(t *testing.T) {
+		path := write("filter.json", `{"min_real_words": 0, "avg_logprob": -1.5, "disable": ["known_phrase"]}`)
+		got, err := loadFilterConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := defaultFilterConfig()
+		want.MinRealWords, want.AvgLogprob, want.Disable = 0, -1.5, []string{rulePhrase}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("loadFilterConfig() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("lists next to the file", func(t *testing.T) {
+		got, err := loadFilterConfig(write("lists.json", `{"lists": ["jingles.txt", "/etc/common.txt"]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{filepath.Join(dir, "jingles.txt"), "/etc/common.txt"}
+		if !reflect.DeepEqual(got.Lists, want) {
+			t.Errorf("Lists = %q, want %q", got.Lists, want)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		if _, err := loadFilterConfig(write("typo.json", `{"min_real_word": 0}`)); err == nil {
+			t.Error("expected an error for an unknown key")
+		}
+	})
+
+	invalid := map[string]func(*filterConfig){
+		"no-speech above 1":     func(c *filterConfig) { c.NoSpeechProb = 1.5 },
+		"positive logprob":      func(c *filterConfig) { c.AvgLogprob = 0.5 },
+		"zero compression":      func(c *filterConfig) { c.Compression = 0 },
+		"unknown metric":        func(c *filterConfig) { c.Metric = "gzip" },
+		"negative min chars":    func(c *filterConfig) { c.MinChars = -1 },
+		"negative min words":    func(c *filterConfig) { c.MinRealWords = -1 },
+		"unknown disabled rule": func(c *filterConfig) { c.Disable = []string{"phrases"} },
+	}
+	for name, change := range invalid {
+		t.Run(name, func(t *testing.T) {
+			c := defaultFilterConfig()
+			change(&c)
+			if err := c.validate(); err == nil {
+				t.Errorf("validate() accepted %+v", c)
+			}
+		})
+	}
+	if err := defaultFilterConfig().validate(); err != nil {
+		t.Errorf("defaults invalid: %v", err)
+	}
+}
+
+func TestFilterConfigRules(t *testing.T) {
+	yes := whisper.Segment{Text: " Yes.", Tokens: []whisper.Token{{P: 0.9}}}
+	andSo := whisper.Segment{Text: " And so", Tokens: []whisper.Token{{P: 0.9}}}
+	mumble := whisper.Segment{Text: "some random words here", Tokens: []whisper.Token{{P: 0.2}}}
+
+	tests := []struct {
+		name    string
+		change  func(*filterConfig)
+		segment whisper.Segment
+		want    string // rule, "" if kept
+	}{
+		{"defaults drop one-word answers", func(*filterConfig) {}, yes, rulePhrase},
+		{"phrases off", func(c *filterConfig) { c.Disable = []string{rulePhrase} }, yes, ""},
+		{"defaults need a real word", func(*filterConfig) {}, andSo, ruleRealWords},
+		{"no real words needed", func(c *filterConfig) { c.MinRealWords = 0 }, andSo, ""},
+		{"all off", func(c *filterConfig) { c.Disable = []string{"all"} }, yes, ""},
+		{"lower logprob threshold", func(c *filterConfig) { c.AvgLogprob = -2 }, mumble, ""},
+		{"stricter logprob threshold", func(*filterConfig) {}, mumble, ruleLogprob},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := defaultFilterConfig()
+			tt.change(&c)
+			if got := filterSegment(tt.segment, c, "").Rule; got != tt.want {
+				t.Errorf("filterSegment() rule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
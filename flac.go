@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mewkiz/flac"
+	"github.com/mewkiz/flac/frame"
+)
+
+// flacDecoder reads native FLAC streams of any bit depth and channel count.
+type flacDecoder struct {
+	stream *flac.Stream
+	frame  *frame.Frame
+	pos    int     // next sample index within frame
+	scale  float32 // 1 / 2^(bitsPerSample-1)
+}
+
+func newFlacDecoder(r io.Reader) (*flacDecoder, error) {
+	stream, err := flac.New(r)
+	if err != nil {
+		return nil, err
+	}
+	info := stream.Info
+	if info.NChannels == 0 || info.SampleRate == 0 || info.BitsPerSample == 0 {
+		return nil, fmt.Errorf("invalid stream info: %d channel(s), %d Hz, %d bits",
+			info.NChannels, info.SampleRate, info.BitsPerSample)
+	}
+	return &flacDecoder{
+		stream: stream,
+		scale:  1 / float32(int64(1)<<(info.BitsPerSample-1)),
+	}, nil
+}
+
+func (d *flacDecoder) SampleRate() int { return int(d.stream.Info.SampleRate) }
+func (d *flacDecoder) Channels() int   { return int(d.stream.Info.NChannels) }
+
+func (d *flacDecoder) Read(buf []float32) (int, error) {
+	channels := d.Channels()
+	out := 0
+	for out+channels <= len(buf) {
+		if d.frame == nil || d.pos >= int(d.frame.BlockSize) {
+			f, err := d.nextFrame()
+			if errors.Is(err, io.EOF) {
+				return out, io.EOF
+			}
+			if err != nil {
+				return out, err
+			}
+			if len(f.Subframes) != channels {
+				return out, fmt.Errorf("frame has %d channel(s), stream has %d", len(f.Subframes), channels)
+			}
+			d.frame = f
+			d.pos = 0
+		}
+		for ; d.pos < int(d.frame.BlockSize) && out+channels <= len(buf); d.pos++ {
+			for c := 0; c < channels; c++ {
+				buf[out] = float32(d.frame.Subframes[c].Samples[d.pos]) * d.scale
+				out++
+			}
+		}
+	}
+	return out, nil
+}
+
+// nextFrame reads and decodes the next frame. A frame header may leave the
+// sample size to STREAMINFO, which the decoder does not fill in itself, so
+// it is copied from the stream before the subframes are parsed.
+func (d *flacDecoder) nextFrame() (*frame.Frame, error) {
+	f, err := d.stream.Next()
+	if err != nil {
+		return nil, err
+	}
+	if f.BitsPerSample == 0 {
+		f.BitsPerSample = d.stream.Info.BitsPerSample
+	}
+	if err := f.Parse(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
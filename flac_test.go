@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// flacBitWriter packs big-endian bit fields, as FLAC frames are laid out.
+type flacBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+func (w *flacBitWriter) write(v uint64, n uint) {
+	for i := int(n) - 1; i >= 0; i-- {
+		w.acc = w.acc<<1 | (v>>uint(i))&1
+		w.nbits++
+		if w.nbits == 8 {
+			w.buf = append(w.buf, byte(w.acc))
+			w.acc, w.nbits = 0, 0
+		}
+	}
+}
+
+func (w *flacBitWriter) align() {
+	for w.nbits != 0 {
+		w.write(0, 1)
+	}
+}
+
+func flacCRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func flacCRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// makeFLAC encodes per-channel integer samples as a FLAC stream using
+// verbatim subframes and independent channels. Block size is fixed at
+// blockSize; the last frame may be shorter.
+func makeFLAC(rate, bits, blockSize int, channels [][]int32) []byte {
+	n := len(channels[0])
+
+	var w flacBitWriter
+	w.buf = append(w.buf, "fLaC"...)
+	w.write(1, 1) // last metadata block
+	w.write(0, 7) // STREAMINFO
+	w.write(34, 24)
+	w.write(uint64(blockSize), 16)
+	w.write(uint64(blockSize), 16)
+	w.write(0, 24) // min frame size unknown
+	w.write(0, 24) // max frame size unknown
+	w.write(uint64(rate), 20)
+	w.write(uint64(len(channels)-1), 3)
+	w.write(uint64(bits-1), 5)
+	w.write(uint64(n), 36)
+	w.write(0, 64) // MD5 unknown
+	w.write(0, 64)
+
+	for num, start := 0, 0; start < n; num, start = num+1, start+blockSize {
+		end := start + blockSize
+		if end > n {
+			end = n
+		}
+		frameStart := len(w.buf)
+		w.write(0x3FFE, 14) // sync
+		w.write(0, 1)       // reserved
+		w.write(0, 1)       // fixed block size
+		w.write(0x7, 4)     // block size: 16-bit value at end of header
+		w.write(0, 4)       // sample rate from STREAMINFO
+		w.write(uint64(len(channels)-1), 4)
+		w.write(0, 3) // sample size from STREAMINFO
+		w.write(0, 1)
+		w.write(uint64(num), 8) // frame number, UTF-8 coded (num < 128)
+		w.write(uint64(end-start-1), 16)
+		w.buf = append(w.buf, flacCRC8(w.buf[frameStart:]))
+
+		for _, ch := range channels {
+			w.write(0, 1) // padding
+			w.write(1, 6) // SUBFRAME_VERBATIM
+			w.write(0, 1) // no wasted bits
+			for _, s := range ch[start:end] {
+				w.write(uint64(s)&(1<<uint(bits)-1), uint(bits))
+			}
+		}
+		w.align()
+		crc := flacCRC16(w.buf[frameStart:])
+		w.buf = append(w.buf, byte(crc>>8), byte(crc))
+	}
+	return w.buf
+}
+
+// flacTestSignal returns n samples per channel covering the full range
+// of a bits-wide signed integer, including both extremes.
+func flacTestSignal(bits, nch, n int) [][]int32 {
+	hi := int32(1)<<uint(bits-1) - 1
+	lo := -hi - 1
+	channels := make([][]int32, nch)
+	for c := range channels {
+		channels[c] = make([]int32, n)
+		for i := range channels[c] {
+			switch (i + c) % 4 {
+			case 0:
+				channels[c][i] = hi
+			case 1:
+				channels[c][i] = lo
+			default:
+				channels[c][i] = int32(i*(c+1)) % hi
+			}
+		}
+	}
+	return channels
+}
+
+func TestFlacDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		bits     int
+		channels int
+	}{
+		{"16-bit stereo", 16, 2},
+		{"24-bit mono", 24, 1},
+		{"8-bit three channels", 8, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signal := flacTestSignal(tt.bits, tt.channels, 40)
+			raw := makeFLAC(44100, tt.bits, 16, signal)
+			d, err := newAudioDecoder(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("newAudioDecoder: %v", err)
+			}
+			if _, ok := d.(*flacDecoder); !ok {
+				t.Fatalf("got decoder %T, want *flacDecoder", d)
+			}
+			if d.Channels() != tt.channels || d.SampleRate() != 44100 {
+				t.Fatalf("got %d ch @ %d Hz, want %d ch @ 44100 Hz", d.Channels(), d.SampleRate(), tt.channels)
+			}
+
+			// A buffer smaller than a frame exercises resuming mid-frame
+			var got []float32
+			buf := make([]float32, 5*tt.channels)
+			for {
+				n, err := d.Read(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read: %v", err)
+				}
+			}
+
+			scale := float32(int64(1) << uint(tt.bits-1))
+			if len(got) != 40*tt.channels {
+				t.Fatalf("got %d samples, want %d", len(got), 40*tt.channels)
+			}
+			for i, v := range got {
+				want := float32(signal[i%tt.channels][i/tt.channels]) / scale
+				if v != want {
+					t.Errorf("sample %d = %f, want %f", i, v, want)
+				}
+			}
+		})
+	}
+}
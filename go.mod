@@ -5,7 +5,15 @@ go 1.23
 require (
 	github.com/ggerganov/whisper.cpp/bindings/go v0.0.0-00010101000000-000000000000
 	github.com/hajimehoshi/go-mp3 v0.3.4
+	github.com/hraban/opus v0.0.0-20251117090126-c76ea7e21bf3
+	github.com/jfreymuth/vorbis v1.0.2
+	github.com/mewkiz/flac v1.0.12
 	github.com/oov/audio v0.0.0-20171004131523-88a2be6dbe38
 )
 
+require (
+	github.com/icza/bitio v1.1.0 // indirect
+	github.com/mewkiz/pkg v0.0.0-20230226050401-4010bf0fec14 // indirect
+)
+
 replace github.com/ggerganov/whisper.cpp/bindings/go => ./whisper.cpp/bindings/go
@@ -49,28 +49,33 @@ func TestGolden(t *testing.T) {
 			}
 			expectedText := strings.TrimSpace(string(expected))
 
-			samples, err := convertToSamples(mp3Path)
+			f, err := os.Open(mp3Path)
+			if err != nil {
+				t.Fatalf("Failed to open audio: %v", err)
+			}
+			samples, err := convertToSamples(f)
+			f.Close()
 			if err != nil {
 				t.Fatalf("Failed to convert audio: %v", err)
 			}
 
-			chunks, err := segmentByVAD(samples)
+			chunks, err := segmentByVAD(samples, defaultVADOptions())
 			if err != nil {
 				t.Fatalf("VAD segmentation failed: %v", err)
 			}
 
+			ctx, err := model.NewContext()
+			if err != nil {
+				t.Fatalf("Failed to create context: %v", err)
+			}
+			if err := ctx.SetLanguage("auto"); err != nil {
+				t.Fatalf("Failed to set language: %v", err)
+			}
+
 			var texts []string
 			for _, chunk := range chunks {
-				ctx, err := model.NewContext()
-				if err != nil {
-					t.Fatalf("Failed to create context: %v", err)
-				}
-				if err := ctx.SetLanguage("auto"); err != nil {
-					t.Fatalf("Failed to set language: %v", err)
-				}
-
 				segmentCb := func(segment whisper.Segment) {
-					if shouldSkipSegment(segment) {
+					if filterSegment(segment, defaultFilterConfig(), ctx.DetectedLanguage()).Dropped() {
 						return
 					}
 					texts = append(texts, strings.TrimSpace(segment.Text))
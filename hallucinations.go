@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
 	"math"
 	"strings"
 	"unicode/utf8"
@@ -164,95 +167,126 @@ const (
 	minRealWords          = 1
 )
 
-// hasRepeatedChars returns true if any character appears 5+ times consecutively.
-func hasRepeatedChars(s string) bool {
+// repeatedChar returns the first character that appears 5+ times
+// consecutively.
+func repeatedChar(s string) (rune, bool) {
 	var prev rune
 	count := 1
 	for _, r := range s {
 		if r == prev {
 			count++
 			if count >= 5 {
-				return true
+				return r, true
 			}
 		} else {
 			prev = r
 			count = 1
 		}
 	}
-	return false
+	return 0, false
 }
 
-// shouldSkipSegment returns true if the segment is likely a hallucination.
-func shouldSkipSegment(segment whisper.Segment) bool {
-	if segment.NoSpeechProb > noSpeechProbThreshold {
-		return true
+// Filter rules, in the order filterSegment checks them.
+const (
+	ruleNoSpeech    = "no_speech_prob"
+	ruleMinChars    = "min_chars"
+	ruleRealWords   = "real_words"
+	rulePhrase      = "known_phrase"
+	rulePrefix      = "known_prefix"
+	rulePattern     = "known_pattern"
+	ruleRepeated    = "repeated_chars"
+	ruleLogprob     = "avg_logprob"
+	ruleCompression = "compression_ratio"
+
+	// Loops are cut back rather than dropped, see loops.go
+	ruleWordLoop    = "word_loop"
+	ruleSegmentLoop = "segment_loop"
+)
+
+// filterVerdict says why the hallucination filter dropped a segment: the
+// rule that fired, the value it measured and the threshold it was held to,
+// or the phrase, prefix or character that matched. Rule is empty for a
+// segment that passed.
+type filterVerdict struct {
+	Rule      string  `json:"rule"`
+	Value     float64 `json:"value,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Match     string  `json:"match,omitempty"`
+}
+
+// Dropped reports whether a rule fired.
+func (v filterVerdict) Dropped() bool {
+	return v.Rule != ""
+}
+
+func (v filterVerdict) String() string {
+	switch {
+	case !v.Dropped():
+		return "kept"
+	case v.Match != "":
+		return fmt.Sprintf("%s %q", v.Rule, v.Match)
+	default:
+		return fmt.Sprintf("%s %.4g (threshold %g)", v.Rule, v.Value, v.Threshold)
 	}
+}
 
-	text := strings.TrimSpace(segment.Text)
-	if utf8.RuneCountInString(text) < minSegmentChars {
-		return true
+// filterSegment runs the hallucination rules enabled in cfg on segment, a
+// segment of text in lang, and returns the verdict of the first one that
+// fires. lang selects the stopwords and the language sections of the
+// phrase lists; with "" only the entries for every language apply.
+func filterSegment(segment whisper.Segment, cfg filterConfig, lang string) filterVerdict {
+	if cfg.enabled(ruleNoSpeech) && float64(segment.NoSpeechProb) > cfg.NoSpeechProb {
+		return filterVerdict{Rule: ruleNoSpeech, Value: float64(segment.NoSpeechProb), Threshold: cfg.NoSpeechProb}
 	}
 
-	if !hasRealWords(text, minRealWords) {
-		return true
+	text := strings.TrimSpace(segment.Text)
+	if n := utf8.RuneCountInString(text); cfg.enabled(ruleMinChars) && n < cfg.MinChars {
+		return filterVerdict{Rule: ruleMinChars, Value: float64(n), Threshold: float64(cfg.MinChars)}
 	}
 
-	if isKnownHallucination(text) {
-		return true
+	if n := countRealWords(text, cfg.stopwordSets().forLang(lang)); cfg.enabled(ruleRealWords) && n < cfg.MinRealWords {
+		return filterVerdict{Rule: ruleRealWords, Value: float64(n), Threshold: float64(cfg.MinRealWords)}
 	}
 
-	if hasRepeatedChars(text) {
-		return true
+	phrases := cfg.phraseList()
+	if phrase, found := phrases.phrase(text, lang); found && cfg.enabled(rulePhrase) {
+		return filterVerdict{Rule: rulePhrase, Match: phrase}
 	}
 
-	if avgLogprob(segment) < avgLogprobThreshold {
-		return true
+	if prefix, found := phrases.prefix(text, lang); found && cfg.enabled(rulePrefix) {
+		return filterVerdict{Rule: rulePrefix, Match: prefix}
 	}
 
-	if compressionRatio(text) > compressionThreshold {
-		return true
+	if pattern, found := phrases.pattern(text, lang); found && cfg.enabled(rulePattern) {
+		return filterVerdict{Rule: rulePattern, Match: pattern}
 	}
 
-	return false
-}
+	if r, found := repeatedChar(text); found && cfg.enabled(ruleRepeated) {
+		return filterVerdict{Rule: ruleRepeated, Match: string(r)}
+	}
 
-// isKnownHallucination checks exact match and prefix match.
-func isKnownHallucination(text string) bool {
-	normalized := strings.ToLower(strings.TrimSpace(text))
-	if _, found := hallucinationPhrases[normalized]; found {
-		return true
+	if lp := avgLogprob(segment); cfg.enabled(ruleLogprob) && lp < cfg.AvgLogprob {
+		return filterVerdict{Rule: ruleLogprob, Value: lp, Threshold: cfg.AvgLogprob}
 	}
-	for _, prefix := range hallucinationPrefixes {
-		if strings.HasPrefix(normalized, prefix) {
-			return true
-		}
+
+	if cr := cfg.compressionRatio(text); cfg.enabled(ruleCompression) && cr > cfg.Compression {
+		return filterVerdict{Rule: ruleCompression, Value: cr, Threshold: cfg.Compression}
 	}
-	return false
+
+	return filterVerdict{}
 }
 
-// hasRealWords returns true if text contains at least n words with 3+ characters
-// that are not stopwords.
-func hasRealWords(text string, n int) bool {
+// countRealWords counts the words with 3+ letters that are not in stops,
+// ignoring case and surrounding punctuation.
+func countRealWords(text string, stops map[string]struct{}) int {
 	count := 0
 	for _, w := range strings.Fields(text) {
-		if utf8.RuneCountInString(w) >= 3 && !isStopword(w) {
+		w = normalizeWord(w)
+		if _, stop := stops[w]; utf8.RuneCountInString(w) >= 3 && !stop {
 			count++
-			if count >= n {
-				return true
-			}
 		}
 	}
-	return false
-}
-
-var stopwords = map[string]struct{}{
-	"the": {}, "and": {}, "for": {}, "you": {}, "this": {},
-	"that": {}, "with": {}, "from": {}, "have": {}, "are": {},
-}
-
-func isStopword(word string) bool {
-	_, found := stopwords[strings.ToLower(word)]
-	return found
+	return count
 }
 
 // avgLogprob computes the average log probability across text tokens.
@@ -274,9 +308,28 @@ func avgLogprob(segment whisper.Segment) float64 {
 	return sum / float64(count)
 }
 
-// compressionRatio estimates text repetitiveness using a simple
-// character bigram compression ratio.
+// compressionRatio measures text repetitiveness the way OpenAI's Whisper
+// does, which the default threshold of 2.4 comes from: the length of the
+// UTF-8 text over its zlib-compressed length. Loops compress well, so
+// their ratio is high; plain speech stays below 2 however long it is.
 func compressionRatio(text string) float64 {
+	if len(text) == 0 {
+		return 0
+	}
+	var buf bytes.Buffer
+	// Go's default level barely finds matches in a text this short; the
+	// best level comes closest to Python's zlib.compress
+	w, _ := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	w.Write([]byte(text))
+	w.Close()
+	return float64(len(text)) / float64(buf.Len())
+}
+
+// bigramRatio estimates text repetitiveness using a simple
+// character bigram compression ratio. It needs no compression, but grows
+// with the length of the text, so long sentences can exceed thresholds
+// meant for compressionRatio.
+func bigramRatio(text string) float64 {
 	if len(text) == 0 {
 		return 0
 	}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
 )
 
 func TestIsKnownHallucination(t *testing.T) {
+	// Only the phrase list rules, so short phrases are not caught earlier
+	cfg := defaultFilterConfig()
+	for _, r := range filterRules {
+		if r != rulePhrase && r != rulePrefix && r != rulePattern {
+			cfg.Disable = append(cfg.Disable, r)
+		}
+	}
 	tests := []struct {
 		text string
 		want bool
@@ -41,9 +49,9 @@ func TestIsKnownHallucination(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.text, func(t *testing.T) {
-			got := isKnownHallucination(tt.text)
-			if got != tt.want {
-				t.Errorf("isKnownHallucination(%q) = %v, want %v", tt.text, got, tt.want)
+			got := filterSegment(whisper.Segment{Text: tt.text}, cfg, "")
+			if got.Dropped() != tt.want {
+				t.Errorf("filterSegment(%q) = %v, want dropped %v", tt.text, got, tt.want)
 			}
 		})
 	}
@@ -62,9 +70,9 @@ func TestHasRealWords(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.text, func(t *testing.T) {
-			got := hasRealWords(tt.text, tt.n)
+			got := countRealWords(tt.text, builtinStopwords.forLang("en")) >= tt.n
 			if got != tt.want {
-				t.Errorf("hasRealWords(%q, %d) = %v, want %v", tt.text, tt.n, got, tt.want)
+				t.Errorf("countRealWords(%q) >= %d is %v, want %v", tt.text, tt.n, got, tt.want)
 			}
 		})
 	}
@@ -82,9 +90,9 @@ func TestHasRepeatedChars(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.text, func(t *testing.T) {
-			got := hasRepeatedChars(tt.text)
+			_, got := repeatedChar(tt.text)
 			if got != tt.want {
-				t.Errorf("hasRepeatedChars(%q) = %v, want %v", tt.text, got, tt.want)
+				t.Errorf("repeatedChar(%q) found = %v, want %v", tt.text, got, tt.want)
 			}
 		})
 	}
@@ -102,6 +110,45 @@ func TestCompressionRatio(t *testing.T) {
 	}
 }
 
+func TestCompressionMetrics(t *testing.T) {
+	meeting := "So what we decided at the last meeting was that the budget for the next quarter would be split " +
+		"between the marketing team and the engineering team, and that the engineering team would get a bit more " +
+		"of it because of the new product launch that is planned for the spring."
+	tests := []struct {
+		name   string
+		text   string
+		zlib   bool // above the 2.4 threshold
+		bigram bool
+	}{
+		{"short sentence", "Hello, this is a test of the transcription system.", false, false},
+		{"long sentence", meeting, false, true},
+		{"long cyrillic sentence", "Мы обсудили бюджет на следующий квартал и решили, что большая часть средств пойдет на разработку нового продукта, который мы планируем запустить весной.", false, true},
+		{"phrase loop", strings.Repeat("I'm going to go to the store. ", 4), true, true},
+		{"word loop", strings.Repeat("the ", 16), true, true},
+		{"thank you loop", strings.Repeat("Thank you. ", 6), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := strings.TrimSpace(tt.text)
+			if got := compressionRatio(text); (got > compressionThreshold) != tt.zlib {
+				t.Errorf("compressionRatio() = %.2f, want above %v: %v", got, compressionThreshold, tt.zlib)
+			}
+			if got := bigramRatio(text); (got > compressionThreshold) != tt.bigram {
+				t.Errorf("bigramRatio() = %.2f, want above %v: %v", got, compressionThreshold, tt.bigram)
+			}
+		})
+	}
+
+	if got := compressionRatio(""); got != 0 {
+		t.Errorf("compressionRatio(\"\") = %v, want 0", got)
+	}
+	cfg := defaultFilterConfig()
+	cfg.Metric = "bigram"
+	if got, want := cfg.compressionRatio(meeting), bigramRatio(meeting); got != want {
+		t.Errorf("bigram metric = %v, want %v", got, want)
+	}
+}
+
 func TestShouldSkipSegment(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -175,9 +222,67 @@ func TestShouldSkipSegment(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := shouldSkipSegment(tt.segment)
+			got := filterSegment(tt.segment, defaultFilterConfig(), "")
+			if got.Dropped() != tt.want {
+				t.Errorf("filterSegment() = %v, want dropped %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterSegment(t *testing.T) {
+	tests := []struct {
+		name    string
+		segment whisper.Segment
+		want    filterVerdict
+	}{
+		{
+			name:    "no speech",
+			segment: whisper.Segment{Text: "Some real text here", NoSpeechProb: 0.75, Tokens: []whisper.Token{{P: 0.9}}},
+			want:    filterVerdict{Rule: ruleNoSpeech, Value: 0.75, Threshold: noSpeechProbThreshold},
+		},
+		{
+			name:    "too short",
+			segment: whisper.Segment{Text: " ok", Tokens: []whisper.Token{{P: 0.9}}},
+			want:    filterVerdict{Rule: ruleMinChars, Value: 2, Threshold: minSegmentChars},
+		},
+		{
+			name:    "only stopwords",
+			segment: whisper.Segment{Text: "and the", Tokens: []whisper.Token{{P: 0.9}}},
+			want:    filterVerdict{Rule: ruleRealWords, Value: 0, Threshold: minRealWords},
+		},
+		{
+			name:    "phrase",
+			segment: whisper.Segment{Text: " Thanks for watching!", Tokens: []whisper.Token{{P: 0.9}}},
+			want:    filterVerdict{Rule: rulePhrase, Match: "thanks for watching!"},
+		},
+		{
+			name:    "prefix",
+			segment: whisper.Segment{Text: "Subtitles by someone", Tokens: []whisper.Token{{P: 0.9}}},
+			want:    filterVerdict{Rule: rulePrefix, Match: "subtitles"},
+		},
+		{
+			name:    "repeated characters",
+			segment: whisper.Segment{Text: "Nooooooo way", Tokens: []whisper.Token{{P: 0.9}}},
+			want:    filterVerdict{Rule: ruleRepeated, Match: "o"},
+		},
+		{
+			name:    "low logprob",
+			segment: whisper.Segment{Text: "some random words here", Tokens: []whisper.Token{{P: 0.25}}},
+			want:    filterVerdict{Rule: ruleLogprob, Value: avgLogprob(whisper.Segment{Tokens: []whisper.Token{{P: 0.25}}}), Threshold: avgLogprobThreshold},
+		},
+		{
+			name:    "kept",
+			segment: whisper.Segment{Text: "Hello, this is a test of the transcription system.", NoSpeechProb: 0.1, Tokens: []whisper.Token{{P: 0.9}}},
+			want:    filterVerdict{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterSegment(tt.segment, defaultFilterConfig(), "")
 			if got != tt.want {
-				t.Errorf("shouldSkipSegment() = %v, want %v", got, tt.want)
+				t.Errorf("filterSegment() = %+v, want %+v", got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// speechInterval is a speech chunk found by VAD, in seconds from the start
+// of the input. Used by -vad-only, which skips transcription.
+type speechInterval struct {
+	Start, End float64
+	Speaker    string
+}
+
+// speechStats summarizes the speech found on one track.
+type speechStats struct {
+	Speaker string  `json:"speaker,omitempty"`
+	Chunks  int     `json:"chunks"`
+	Speech  float64 `json:"speech_seconds"`
+	Audio   float64 `json:"audio_seconds"`
+	Ratio   float64 `json:"speech_ratio"`
+}
+
+// computeSpeechStats aggregates per speaker, in the order of speakers, the
+// number of chunks and the speech they hold. speech should be the detected
+// speech without padding or the silence bridged by merging chunks (see
+// audioSegment), so neither counts as talk time. Speech time is the length
+// of the union of a speaker's intervals, in case any overlap. audio is the
+// length of the processed range, the same for every track.
+func computeSpeechStats(chunks, speech []speechInterval, speakers []string, audio float64) []speechStats {
+	stats := make([]speechStats, len(speakers))
+	for i, sp := range speakers {
+		st := speechStats{Speaker: sp, Audio: audio}
+		for _, c := range chunks {
+			if c.Speaker == sp {
+				st.Chunks++
+			}
+		}
+		var own []speechInterval
+		for _, iv := range speech {
+			if iv.Speaker == sp {
+				own = append(own, iv)
+			}
+		}
+		sort.Slice(own, func(a, b int) bool { return own[a].Start < own[b].Start })
+		covered := 0.0 // end of the union so far
+		for _, iv := range own {
+			start := max(iv.Start, covered)
+			if iv.End > start {
+				st.Speech += iv.End - start
+			}
+			covered = max(covered, iv.End)
+		}
+		if audio > 0 {
+			st.Ratio = st.Speech / audio
+		}
+		st.Speech, st.Audio = roundMillis(st.Speech), roundMillis(st.Audio)
+		stats[i] = st
+	}
+	return stats
+}
+
+// isIntervalFormat reports whether writeIntervals supports format.
+func isIntervalFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "txt", "json", "audacity", "labels":
+		return true
+	}
+	return false
+}
+
+// writeIntervals renders speech intervals as txt, json, or audacity (a
+// label track Audacity can import with File > Import > Labels). Only the
+// json format carries the stats.
+func writeIntervals(out io.Writer, format string, intervals []speechInterval, stats []speechStats) error {
+	switch strings.ToLower(format) {
+	case "json":
+		type jsonInterval struct {
+			Start   string  `json:"start"`
+			End     string  `json:"end"`
+			Speaker string  `json:"speaker,omitempty"`
+			Length  float64 `json:"duration"`
+		}
+		doc := struct {
+			Intervals []jsonInterval `json:"intervals"`
+			Stats     []speechStats  `json:"stats"`
+		}{Intervals: []jsonInterval{}, Stats: stats}
+		for _, iv := range intervals {
+			doc.Intervals = append(doc.Intervals, jsonInterval{
+				Start:   formatDuration(secondsDuration(iv.Start)),
+				End:     formatDuration(secondsDuration(iv.End)),
+				Speaker: iv.Speaker,
+				Length:  roundMillis(iv.End - iv.Start),
+			})
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case "audacity", "labels":
+		for _, iv := range intervals {
+			label := iv.Speaker
+			if label == "" {
+				label = "speech"
+			}
+			fmt.Fprintf(out, "%.6f\t%.6f\t%s\n", iv.Start, iv.End, label)
+		}
+	case "txt":
+		for _, iv := range intervals {
+			line := fmt.Sprintf("[%s -> %s]", formatDuration(secondsDuration(iv.Start)), formatDuration(secondsDuration(iv.End)))
+			if iv.Speaker != "" {
+				line += " " + iv.Speaker
+			}
+			fmt.Fprintln(out, line)
+		}
+	default:
+		return fmt.Errorf("unsupported format %q for -vad-only (want txt, json or audacity)", format)
+	}
+	return nil
+}
+
+// printSpeechStats writes a human-readable summary of stats to w.
+func printSpeechStats(w io.Writer, stats []speechStats) {
+	for _, st := range stats {
+		prefix := ""
+		if st.Speaker != "" {
+			prefix = st.Speaker + ": "
+		}
+		fmt.Fprintf(w, "%s%d speech chunk(s), %s of speech in %s (%.1f%%)\n",
+			prefix, st.Chunks,
+			formatDuration(secondsDuration(st.Speech)), formatDuration(secondsDuration(st.Audio)),
+			st.Ratio*100)
+	}
+}
+
+// roundMillis rounds seconds to milliseconds, the precision of every
+// timestamp in the output, so JSON shows 1.9 rather than 1.9000000000000001.
+func roundMillis(sec float64) float64 {
+	return math.Round(sec*1000) / 1000
+}
+
+func secondsDuration(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeSpeechStats(t *testing.T) {
+	intervals := []speechInterval{
+		{Start: 1, End: 3, Speaker: "agent"},
+		{Start: 2.5, End: 4, Speaker: "agent"}, // overlaps the previous chunk's padding
+		{Start: 10, End: 11, Speaker: "agent"},
+		{Start: 5, End: 9, Speaker: "customer"},
+	}
+	stats := computeSpeechStats(intervals, intervals, []string{"agent", "customer", "nobody"}, 20)
+
+	want := []speechStats{
+		{Speaker: "agent", Chunks: 3, Speech: 4, Audio: 20, Ratio: 0.2},
+		{Speaker: "customer", Chunks: 1, Speech: 4, Audio: 20, Ratio: 0.2},
+		{Speaker: "nobody", Chunks: 0, Speech: 0, Audio: 20, Ratio: 0},
+	}
+	for i, w := range want {
+		got := stats[i]
+		if got.Speaker != w.Speaker || got.Chunks != w.Chunks ||
+			math.Abs(got.Speech-w.Speech) > 1e-9 || math.Abs(got.Ratio-w.Ratio) > 1e-9 {
+			t.Errorf("stats[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestComputeSpeechStatsPadding(t *testing.T) {
+	// Two 30-frame regions: 60 frames of 256 samples are 0.96s of speech
+	probs := frameProbs(20, 0.1, 30, 0.9, 40, 0.1, 30, 0.9, 40, 0.1)
+	audio := float64(len(probs)*hopSize) / sampleRate
+
+	tests := []struct {
+		name       string
+		padding    time.Duration
+		merge      time.Duration // MergeTarget
+		wantChunks int
+	}{
+		{"no padding", 0, 0, 2},
+		{"200ms padding", 200 * time.Millisecond, 0, 2},
+		{"1s padding", time.Second, 0, 2},
+		// The 0.64s of silence between the regions is bridged, not speech
+		{"merged", 200 * time.Millisecond, 5 * time.Second, 1},
+	}
+	for _, tt := range tests {
+		opts := defaultVADOptions()
+		opts.Padding = tt.padding
+		opts.MergeTarget = tt.merge
+		var chunks, speech []speechInterval
+		for _, seg := range segmentProbs(t, opts, probs) {
+			chunks = append(chunks, speechInterval{Start: seg.startSec, End: seg.startSec + float64(len(seg.samples))/sampleRate})
+			for _, sp := range seg.speech {
+				speech = append(speech, speechInterval{Start: sp[0], End: sp[1]})
+			}
+		}
+		stats := computeSpeechStats(chunks, speech, []string{""}, audio)
+		if got := stats[0]; got.Chunks != tt.wantChunks || got.Speech != 0.96 {
+			t.Errorf("%s: got %d chunks, %gs of speech, want %d chunks, 0.96s", tt.name, got.Chunks, got.Speech, tt.wantChunks)
+		}
+	}
+}
+
+func TestWriteIntervals(t *testing.T) {
+	intervals := []speechInterval{
+		{Start: 1.2, End: 3.1},
+		{Start: 3.4, End: 6},
+	}
+	stats := computeSpeechStats(intervals, intervals, []string{""}, 10)
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"txt", []string{"[00:00:01.200 -> 00:00:03.100]\n[00:00:03.400 -> 00:00:06.000]\n"}},
+		{"audacity", []string{"1.200000\t3.100000\tspeech\n3.400000\t6.000000\tspeech\n"}},
+		{"json", []string{`"start": "00:00:01.200"`, "\"duration\": 1.9\n", `"speech_seconds": 4.5`, `"speech_ratio": 0.45`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeIntervals(&buf, tt.format, intervals, stats); err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(buf.String(), w) {
+					t.Errorf("output missing %q:\n%s", w, buf.String())
+				}
+			}
+		})
+	}
+
+	if err := writeIntervals(&bytes.Buffer{}, "srt", intervals, stats); err == nil {
+		t.Error("expected error for srt")
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Whisper's decoder can get stuck repeating itself: a phrase looping within
+// a segment ("I'm going to go to the store. I'm going to go to the store.")
+// or the same sentence in segment after segment. Rather than dropping the
+// text, loops are cut back to their first occurrence.
+
+// minSegmentLoop is how many consecutive segments of a speaker with the
+// same text make a loop. Two can be a real repetition.
+const minSegmentLoop = 3
+
+// minLoopRepeats is how many consecutive times an n-word sequence must
+// occur to be a loop. Short sequences repeat in real speech ("no, no"), so
+// they need more occurrences.
+func minLoopRepeats(n int) int {
+	switch {
+	case n == 1:
+		return 4
+	case n <= 3:
+		return 3
+	}
+	return 2
+}
+
+// normalizeWord lowercases w and strips surrounding punctuation, so that
+// "store." and "store" compare equal.
+func normalizeWord(w string) string {
+	return strings.ToLower(strings.TrimFunc(w, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}))
+}
+
+// wordLoop is a sequence of words, words[start:first], repeated back to
+// back up to words[end-1]. The repeats, words[first:end], are cut.
+type wordLoop struct {
+	start, first, end int
+	repeats           int // full occurrences, the first included
+}
+
+// findLoops returns the word loops in words, in order. A partial repeat cut
+// off by the end of the segment is part of the loop.
+func findLoops(words []string) []wordLoop {
+	norm := make([]string, len(words))
+	for i, w := range words {
+		norm[i] = normalizeWord(w)
+	}
+	var loops []wordLoop
+	for i := 0; i < len(norm); {
+		l, ok := loopAt(norm, i)
+		if !ok {
+			i++
+			continue
+		}
+		loops = append(loops, l)
+		i = l.end
+	}
+	return loops
+}
+
+// loopMask returns which words to keep when each loop in words is cut back
+// to its first occurrence, or nil if there is no loop.
+func loopMask(words []string) []bool {
+	loops := findLoops(words)
+	if loops == nil {
+		return nil
+	}
+	keep := make([]bool, len(words))
+	for j := range keep {
+		keep[j] = true
+	}
+	for _, l := range loops {
+		for j := l.first; j < l.end; j++ {
+			keep[j] = false
+		}
+	}
+	return keep
+}
+
+// loopAt looks for a loop starting at word i, trying the shortest sequence
+// first.
+func loopAt(norm []string, i int) (wordLoop, bool) {
+	for n := 1; i+2*n <= len(norm); n++ {
+		gram := norm[i : i+n]
+		repeats := 1
+		for j := i + n; j+n <= len(norm) && slices.Equal(norm[j:j+n], gram); j += n {
+			repeats++
+		}
+		if repeats < minLoopRepeats(n) {
+			continue
+		}
+		end := i + repeats*n
+		// A loop running to the end of the segment usually stops mid-repeat
+		rest := norm[end:]
+		if len(rest) < n && slices.Equal(rest, gram[:len(rest)]) {
+			end = len(norm)
+		}
+		return wordLoop{start: i, first: i + n, end: end, repeats: repeats}, true
+	}
+	return wordLoop{}, false
+}
+
+// truncateLoops cuts the word loops in text back to their first occurrence.
+// It returns the mask of words kept, from strings.Fields(text), or nil if
+// text has no loop and is returned unchanged.
+func truncateLoops(text string) (string, []bool) {
+	words := strings.Fields(text)
+	keep := loopMask(words)
+	if keep == nil {
+		return text, nil
+	}
+	var kept []string
+	for i, w := range words {
+		if keep[i] {
+			kept = append(kept, w)
+		}
+	}
+	// Keep whisper's leading space
+	lead := text[:len(text)-len(strings.TrimLeftFunc(text, unicode.IsSpace))]
+	return lead + strings.Join(kept, " "), keep
+}
+
+// wordLoopReport lists the loops truncateLoops cuts from text for the filter
+// report, one entry per loop, at the times of seg: the words cut as the
+// text, the repeated sequence as the match and how often it occurred as the
+// value.
+func wordLoopReport(seg transcriptSegment, text string) []droppedSegment {
+	words := strings.Fields(text)
+	var report []droppedSegment
+	for _, l := range findLoops(words) {
+		cut := seg
+		cut.Text = strings.Join(words[l.first:l.end], " ")
+		report = append(report, droppedSegment{cut, filterVerdict{
+			Rule:      ruleWordLoop,
+			Value:     float64(l.repeats),
+			Threshold: float64(minLoopRepeats(l.first - l.start)),
+			Match:     strings.Join(words[l.start:l.first], " "),
+		}})
+	}
+	return report
+}
+
+// maskWords drops the words of a segment whose text truncateLoops cut.
+// Words are rebuilt from tokens and nearly always line up with the text's
+// fields; if they do not, they are left as they are.
+func maskWords(words []transcriptWord, keep []bool) []transcriptWord {
+	if keep == nil || len(words) != len(keep) {
+		return words
+	}
+	var kept []transcriptWord
+	for i, w := range words {
+		if keep[i] {
+			kept = append(kept, w)
+		}
+	}
+	return kept
+}
+
+// collapseSegmentLoops cuts runs of minSegmentLoop or more consecutive
+// segments of one speaker with the same text back to the first, and
+// returns the remaining segments and those cut.
+func collapseSegmentLoops(segments []transcriptSegment) ([]transcriptSegment, []droppedSegment) {
+	type run struct {
+		text  string
+		first int // index of the segment kept
+		count int
+	}
+	runs := map[string]*run{} // current run per speaker
+	runOf := make([]*run, len(segments))
+	for i, seg := range segments {
+		text := normalizeText(seg.Text)
+		r := runs[seg.Speaker]
+		if r == nil || r.text != text {
+			r = &run{text: text, first: i}
+			runs[seg.Speaker] = r
+		}
+		r.count++
+		runOf[i] = r
+	}
+
+	var kept []transcriptSegment
+	var dropped []droppedSegment
+	for i, seg := range segments {
+		r := runOf[i]
+		if r.count < minSegmentLoop || i == r.first {
+			kept = append(kept, seg)
+			continue
+		}
+		dropped = append(dropped, droppedSegment{seg, filterVerdict{
+			Rule:      ruleSegmentLoop,
+			Value:     float64(r.count),
+			Threshold: minSegmentLoop,
+			Match:     strings.TrimSpace(segments[r.first].Text),
+		}})
+	}
+	return kept, dropped
+}
+
+// normalizeText joins the normalized words of text.
+func normalizeText(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		words[i] = normalizeWord(w)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTruncateLoops(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "sentence twice",
+			text: " I'm going to go to the store. I'm going to go to the store.",
+			want: " I'm going to go to the store.",
+		},
+		{
+			name: "loop then more text",
+			text: " We need to check the logs, we need to check the logs, we need to check the logs and restart it.",
+			want: " We need to check the logs, and restart it.",
+		},
+		{
+			name: "cut off mid-repeat at the end",
+			text: " Please hold the line. Please hold the line. Please hold",
+			want: " Please hold the line.",
+		},
+		{
+			name: "word repeated",
+			text: " The the the the the the",
+			want: " The",
+		},
+		{
+			name: "short phrase three times",
+			text: " Thank you, thank you, thank you.",
+			want: " Thank you,",
+		},
+		{
+			name: "natural repetition kept",
+			text: " No, no, I said the report, not the reports.",
+			want: " No, no, I said the report, not the reports.",
+		},
+		{
+			name: "very very kept",
+			text: " It was very, very good.",
+			want: " It was very, very good.",
+		},
+		{
+			name: "empty",
+			text: "",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, keep := truncateLoops(tt.text)
+			if got != tt.want {
+				t.Errorf("truncateLoops() = %q, want %q", got, tt.want)
+			}
+			if (keep == nil) != (got == tt.text) {
+				t.Errorf("mask = %v for text changed: %v", keep, got != tt.text)
+			}
+		})
+	}
+}
+
+func TestMaskWords(t *testing.T) {
+	words := []transcriptWord{{Word: "Go"}, {Word: "home."}, {Word: "Go"}, {Word: "home."}}
+	keep := []bool{true, true, false, false}
+	if got, want := maskWords(words, keep), words[:2]; !reflect.DeepEqual(got, want) {
+		t.Errorf("maskWords() = %v, want %v", got, want)
+	}
+	// Words that do not line up with the text are left alone
+	if got := maskWords(words[:3], keep); len(got) != 3 {
+		t.Errorf("maskWords() with mismatched mask = %v", got)
+	}
+}
+
+func TestWordLoopReport(t *testing.T) {
+	seg := transcriptSegment{Start: "00:00:01.000", End: "00:00:04.000", Speaker: "agent"}
+	text := " Check the logs, check the logs, check the logs and the the the the the restart."
+
+	report := wordLoopReport(seg, text)
+	if len(report) != 2 {
+		t.Fatalf("got %d entries, want 2: %+v", len(report), report)
+	}
+	want := []struct {
+		text, match string
+		repeats     float64
+	}{
+		{"check the logs, check the logs", "Check the logs,", 3},
+		{"the the the the", "the", 5},
+	}
+	for i, w := range want {
+		d := report[i]
+		if d.Text != w.text || d.Verdict.Match != w.match || d.Verdict.Value != w.repeats {
+			t.Errorf("entry %d = %q %+v, want %q cut, %v repeats of %q", i, d.Text, d.Verdict, w.text, w.repeats, w.match)
+		}
+		if d.Verdict.Rule != ruleWordLoop || d.Start != seg.Start || d.Speaker != "agent" {
+			t.Errorf("entry %d = %+v, want a word_loop at the segment's times", i, d)
+		}
+	}
+	if report := wordLoopReport(seg, " No, no, it was fine."); report != nil {
+		t.Errorf("report for text without a loop = %+v", report)
+	}
+}
+
+func TestCollapseSegmentLoops(t *testing.T) {
+	seg := func(start, speaker, text string) transcriptSegment {
+		return transcriptSegment{Start: start, End: start, Speaker: speaker, Text: text}
+	}
+	segments := []transcriptSegment{
+		seg("00:00:01.000", "", " Let's get started."),
+		seg("00:00:05.000", "", " Subscribe to the channel."),
+		seg("00:00:09.000", "", " subscribe to the channel"),
+		seg("00:00:13.000", "", " Subscribe to the channel."),
+		seg("00:00:17.000", "", " Subscribe to the channel."),
+		seg("00:00:21.000", "", " Okay, next point."),
+		seg("00:00:25.000", "", " Okay, next point."), // only twice: kept
+		seg("00:00:29.000", "agent", " Yes."),
+		seg("00:00:30.000", "customer", " Hello?"),
+		seg("00:00:31.000", "agent", " Yes."),
+		seg("00:00:32.000", "customer", " Hello?"),
+		seg("00:00:33.000", "agent", " Yes."), // consecutive for the agent
+	}
+
+	kept, dropped := collapseSegmentLoops(segments)
+	var keptStarts, droppedStarts []string
+	for _, s := range kept {
+		keptStarts = append(keptStarts, s.Start)
+	}
+	for _, d := range dropped {
+		droppedStarts = append(droppedStarts, d.Start)
+		if d.Verdict.Rule != ruleSegmentLoop {
+			t.Errorf("rule = %q, want %q", d.Verdict.Rule, ruleSegmentLoop)
+		}
+	}
+	wantKept := []string{"00:00:01.000", "00:00:05.000", "00:00:21.000", "00:00:25.000", "00:00:29.000", "00:00:30.000", "00:00:32.000"}
+	wantDropped := []string{"00:00:09.000", "00:00:13.000", "00:00:17.000", "00:00:31.000", "00:00:33.000"}
+	if !reflect.DeepEqual(keptStarts, wantKept) {
+		t.Errorf("kept %s, want %s", strings.Join(keptStarts, " "), strings.Join(wantKept, " "))
+	}
+	if !reflect.DeepEqual(droppedStarts, wantDropped) {
+		t.Errorf("dropped %s, want %s", strings.Join(droppedStarts, " "), strings.Join(wantDropped, " "))
+	}
+	if v := dropped[0].Verdict; v.Value != 4 || v.Match != "Subscribe to the channel." {
+		t.Errorf("verdict = %+v, want a run of 4 matching the first segment", v)
+	}
+}
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"encoding/binary"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -11,23 +9,22 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
-	mp3 "github.com/hajimehoshi/go-mp3"
-	"github.com/oov/audio/resampler"
 )
 
-type audioSegment struct {
-	samples  []float32
-	startSec float64
-}
-
 type transcriptSegment struct {
-	Start string `json:"start"`
-	End   string `json:"end"`
-	Text  string `json:"text"`
+	Start   string           `json:"start"`
+	End     string           `json:"end"`
+	Speaker string           `json:"speaker,omitempty"`
+	Text    string           `json:"text"`
+	Words   []transcriptWord `json:"words,omitempty"`
+
+	segmentScores // flattened into the JSON object
 }
 
 var defaultModelPath = "models/ggml-large-v3-turbo.bin"
@@ -52,12 +49,57 @@ func main() {
 	lang := flag.String("lang", "auto", "Language code (default: auto-detect)")
 	translate := flag.Bool("translate", false, "Translate to English")
 	prompt := flag.String("prompt", "", "Initial prompt to guide transcription")
-	format := flag.String("format", "txt", "Output format: txt, json, srt, md")
+	carryTokens := flag.Int("carry-tokens", 0, fmt.Sprintf("Add up to this many tokens of the previous chunk's text to the next chunk's prompt (0-%d, 0 = off; needs a single worker)", maxCarryTokens))
+	format := flag.String("format", "txt", "Output format: txt, json, srt, md (with -vad-only: txt, json, audacity)")
 	output := flag.String("output", "", "Output file (default: stdout)")
-	threads := flag.Int("threads", runtime.NumCPU(), "Number of threads")
+	decoding := flag.String("decoding", "speed", "Decoding preset: speed (greedy, no fallback) or accuracy (beam search 5, temperature fallback)")
+	beamSize := flag.Int("beam-size", 0, "Beam search size, 1 = greedy (overrides -decoding)")
+	bestOf := flag.Int("best-of", 0, "Candidates sampled per fallback temperature (overrides -decoding)")
+	temperature := flag.Float64("temperature", 0, "Initial sampling temperature (overrides -decoding)")
+	temperatureInc := flag.Float64("temperature-inc", 0, "Temperature fallback step, e.g. 0.2; 0 disables fallback (overrides -decoding)")
+	confidence := flag.Bool("confidence", false, "Add confidence scores to txt, srt and md output (always in json)")
+	wordTimestamps := flag.Bool("word-timestamps", false, "Add per-word start and end times to JSON output")
+	threads := flag.Int("threads", runtime.NumCPU(), "Number of threads, split evenly between workers")
+	workers := flag.Int("workers", 1, "Chunks transcribed in parallel, sharing one loaded model")
+	channel := flag.String("channel", "mix", "Channel mode: mix, left, right, or separate (transcribe each channel on its own)")
+	from := flag.String("from", "", "Start of the range to transcribe: seconds, MM:SS, HH:MM:SS or a Go duration like 42m")
+	to := flag.String("to", "", "End of the range to transcribe (same formats as -from; default: end of input)")
+	vadDefaults := defaultVADOptions()
+	vadBackend := flag.String("vad", vadDefaults.Backend, "VAD backend: ten (neural, needs the ten-vad library) or energy (pure Go, for clean audio)")
+	vadThreshold := flag.Float64("vad-threshold", float64(vadDefaults.Threshold), "VAD speech probability threshold, 0-1 (raise for noisy recordings)")
+	vadMinSilence := flag.Duration("vad-min-silence", vadDefaults.MinSilence, "Silence needed to split speech into chunks")
+	vadPadding := flag.Duration("vad-padding", vadDefaults.Padding, "Audio kept before and after each speech chunk")
+	vadMinSpeech := flag.Duration("vad-min-speech", vadDefaults.MinSpeech, "Drop speech chunks shorter than this")
+	maxChunk := flag.Duration("max-chunk-duration", vadDefaults.MaxChunk, "Split speech longer than this at the quietest point, e.g. 30s (0 = no limit)")
+	mergeGap := flag.Duration("merge-gap", vadDefaults.MergeGap, "Merge speech chunks separated by at most this much silence (see -merge-target)")
+	mergeTarget := flag.Duration("merge-target", vadDefaults.MergeTarget, "Longest chunk that merging may produce, e.g. 20s (0 = no merging)")
+	speakers := flag.String("speakers", "", "Comma-separated speaker names per channel, e.g. agent,customer (default: left,right in separate mode)")
+	timelinePath := flag.String("vad-timeline", "", "Write per-frame VAD probabilities and chunk boundaries to this file for debugging (.csv or .json)")
+	filterDefaults := defaultFilterConfig()
+	filterConfigPath := flag.String("filter-config", "", "JSON file with hallucination filter thresholds and disabled rules (flags below override it)")
+	noSpeechThreshold := flag.Float64("no-speech-threshold", filterDefaults.NoSpeechProb, "Drop segments whose no-speech probability is above this")
+	logprobThreshold := flag.Float64("logprob-threshold", filterDefaults.AvgLogprob, "Drop segments whose average token log-probability is below this")
+	compressionRatioThreshold := flag.Float64("compression-threshold", filterDefaults.Compression, "Drop segments whose compression ratio is above this")
+	compressionMetric := flag.String("compression-metric", filterDefaults.Metric, "How to measure the compression ratio: zlib (as OpenAI Whisper) or bigram (character bigram uniqueness)")
+	minChars := flag.Int("min-chars", filterDefaults.MinChars, "Drop segments shorter than this many characters")
+	minWords := flag.Int("min-real-words", filterDefaults.MinRealWords, "Drop segments with fewer words of 3+ letters that are not stopwords of the segment's language")
+	filterDisable := flag.String("filter-disable", "", "Comma-separated hallucination filter rules to turn off, or all: "+strings.Join(filterRules, ", "))
+	var phraseLists []string
+	flag.Func("hallucination-list", "File of extra hallucination phrases, prefixes and patterns to drop (repeatable)", func(path string) error {
+		phraseLists = append(phraseLists, path)
+		return nil
+	})
+	var stopwordFiles []string
+	flag.Func("stopwords", "File of extra stopwords by language for -min-real-words (repeatable)", func(path string) error {
+		stopwordFiles = append(stopwordFiles, path)
+		return nil
+	})
+	replaceBuiltin := flag.Bool("replace-builtin-list", false, "Use only the -hallucination-list files, not the built-in phrase list")
+	filterReport := flag.String("filter-report", "", "Write segments dropped by the hallucination filter, with the rule that fired, to this file (.json or text)")
+	vadOnly := flag.Bool("vad-only", false, "Only detect speech: output speech intervals and stats, no model needed")
 	help := flag.Bool("help", false, "Show help")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: whisper-ihm [flags] <input.mp3>\n\nFlags:\n")
+		fmt.Fprintf(os.Stderr, "Usage: whisper-ihm [flags] <audio file | ->\n\nUse - to read audio from stdin.\n\nFlags:\n")
 		flag.PrintDefaults()
 	}
 	flag.Parse()
@@ -75,278 +117,363 @@ func main() {
 	}
 	inputPath := flag.Arg(0)
 
-	// Resolve model path
-	resolvedModel := *modelPath
-	if resolvedModel == "" {
-		info, ok := modelSizes[*size]
-		if !ok {
-			fmt.Fprintf(os.Stderr, "Unknown model size %q. Available models:\n", *size)
-			printModelList()
+	mode, err := parseChannelMode(*channel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fromSec, err := parseTimeArg(*from)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -from: %v\n", err)
+		os.Exit(1)
+	}
+	toSec, err := parseTimeArg(*to)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: invalid -to: %v\n", err)
+		os.Exit(1)
+	}
+	if toSec > 0 && toSec <= fromSec {
+		fmt.Fprintf(os.Stderr, "Error: -to must be after -from\n")
+		os.Exit(1)
+	}
+	if *vadOnly && !isIntervalFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Error: -vad-only supports -format txt, json or audacity, not %q\n", *format)
+		os.Exit(1)
+	}
+	vadOpts := VADOptions{
+		Backend:     *vadBackend,
+		Threshold:   float32(*vadThreshold),
+		MinSilence:  *vadMinSilence,
+		Padding:     *vadPadding,
+		MinSpeech:   *vadMinSpeech,
+		MaxChunk:    *maxChunk,
+		MergeGap:    *mergeGap,
+		MergeTarget: *mergeTarget,
+	}
+	if err := vadOpts.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if inputPath != "-" {
+		if _, err := os.Stat(inputPath); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "Error: input file %q not found\n", inputPath)
 			os.Exit(1)
 		}
-		resolvedModel = filepath.Join(filepath.Dir(defaultModelPath), info.file)
 	}
 
-	if _, err := os.Stat(inputPath); os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: input file %q not found\n", inputPath)
+	decodingOpts, err := decodingPreset(*decoding)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	// Flags given explicitly override the preset
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "beam-size":
+			decodingOpts.BeamSize = *beamSize
+		case "best-of":
+			decodingOpts.BestOf = *bestOf
+		case "temperature":
+			decodingOpts.Temperature = float32(*temperature)
+		case "temperature-inc":
+			decodingOpts.TemperatureInc = float32(*temperatureInc)
+		}
+	})
+	if err := decodingOpts.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	if _, err := os.Stat(resolvedModel); os.IsNotExist(err) {
-		if *modelPath != "" {
-			fmt.Fprintf(os.Stderr, "Error: model not found at %s\n", resolvedModel)
+	filterCfg := defaultFilterConfig()
+	if *filterConfigPath != "" {
+		if filterCfg, err = loadFilterConfig(*filterConfigPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading filter config: %v\n", err)
 			os.Exit(1)
 		}
-		info := modelSizes[*size]
-		fmt.Fprintf(os.Stderr, "Model not found at %s\n", resolvedModel)
-		fmt.Fprintf(os.Stderr, "Downloading %s (~%s)...\n", info.file, info.size)
-		if err := downloadModel(resolvedModel, info.file); err != nil {
-			fmt.Fprintf(os.Stderr, "Error downloading model: %v\n", err)
-			os.Exit(1)
+	}
+	// Flags given explicitly override the config file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "no-speech-threshold":
+			filterCfg.NoSpeechProb = *noSpeechThreshold
+		case "logprob-threshold":
+			filterCfg.AvgLogprob = *logprobThreshold
+		case "compression-threshold":
+			filterCfg.Compression = *compressionRatioThreshold
+		case "compression-metric":
+			filterCfg.Metric = strings.ToLower(*compressionMetric)
+		case "min-chars":
+			filterCfg.MinChars = *minChars
+		case "min-real-words":
+			filterCfg.MinRealWords = *minWords
+		case "filter-disable":
+			filterCfg.Disable = append(filterCfg.Disable, parseRuleList(*filterDisable)...)
+		case "hallucination-list":
+			filterCfg.Lists = append(filterCfg.Lists, phraseLists...)
+		case "stopwords":
+			filterCfg.Stopwords = append(filterCfg.Stopwords, stopwordFiles...)
+		case "replace-builtin-list":
+			filterCfg.ReplaceBuiltin = *replaceBuiltin
 		}
+	})
+	if err := filterCfg.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-
-	fmt.Fprintf(os.Stderr, "Converting audio to 16kHz mono...\n")
-	samples, err := convertToSamples(inputPath)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error converting audio: %v\n", err)
+	if err := filterCfg.loadLists(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading filter lists: %v\n", err)
 		os.Exit(1)
 	}
-	totalSec := float64(len(samples)) / 16000.0
-	fmt.Fprintf(os.Stderr, "Audio loaded: %.1f seconds\n", totalSec)
 
-	fmt.Fprintf(os.Stderr, "Loading model %s...\n", resolvedModel)
-	model, err := whisper.New(resolvedModel)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading model: %v\n", err)
+	if *workers < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -workers must be at least 1\n")
 		os.Exit(1)
 	}
-	defer model.Close()
-
-	fmt.Fprintf(os.Stderr, "Detecting speech segments...\n")
-	chunks, err := segmentByVAD(samples)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error in VAD segmentation: %v\n", err)
+	if *carryTokens < 0 || *carryTokens > maxCarryTokens {
+		fmt.Fprintf(os.Stderr, "Error: -carry-tokens must be between 0 and %d\n", maxCarryTokens)
 		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Found %d speech chunk(s)\n", len(chunks))
-
-	var segments []transcriptSegment
+	if *carryTokens > 0 && *workers > 1 {
+		// Each chunk's prompt depends on the previous chunk's result
+		fmt.Fprintf(os.Stderr, "Warning: -carry-tokens transcribes chunks in order, ignoring -workers %d\n", *workers)
+		*workers = 1
+	}
 
-	for i, chunk := range chunks {
-		ctx, err := model.NewContext()
+	// -vad-only never touches the model, so it is not resolved or downloaded.
+	// Workers share the one loaded model, each with its own decoding state.
+	var model whisper.Model
+	if !*vadOnly {
+		resolvedModel := resolveModel(*modelPath, *size)
+		fmt.Fprintf(os.Stderr, "Loading model %s...\n", resolvedModel)
+		model, err = whisper.New(resolvedModel)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating context: %v\n", err)
-			os.Exit(1)
-		}
-		if err := ctx.SetLanguage(*lang); err != nil {
-			fmt.Fprintf(os.Stderr, "Error setting language %q: %v\n", *lang, err)
-			os.Exit(1)
-		}
-		ctx.SetThreads(uint(*threads))
-		ctx.SetTranslate(*translate)
-		ctx.SetBeamSize(1)
-		ctx.SetTemperature(0)
-		ctx.SetTemperatureFallback(-1)
-		if *prompt != "" {
-			ctx.SetInitialPrompt(*prompt)
-		}
-
-		offset := time.Duration(chunk.startSec * float64(time.Second))
-		segmentCb := func(segment whisper.Segment) {
-			if shouldSkipSegment(segment) {
-				return
-			}
-			segments = append(segments, transcriptSegment{
-				Start: formatDuration(segment.Start + offset),
-				End:   formatDuration(segment.End + offset),
-				Text:  segment.Text,
-			})
-		}
-		if err := ctx.Process(chunk.samples, nil, segmentCb, nil); err != nil {
-			fmt.Fprintf(os.Stderr, "Error processing chunk %d: %v\n", i+1, err)
+			fmt.Fprintf(os.Stderr, "Error loading model: %v\n", err)
 			os.Exit(1)
 		}
+		defer model.Close()
 	}
 
-	segments = deduplicateSegments(segments)
-
-	// Write output
-	out := os.Stdout
-	if *output != "" {
-		f, err := os.Create(*output)
+	// "-" reads from stdin; decoders only need an io.Reader, so pipes work
+	in := os.Stdin
+	if inputPath != "-" {
+		f, err := os.Open(inputPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error opening input: %v\n", err)
 			os.Exit(1)
 		}
 		defer f.Close()
-		out = f
+		in = f
 	}
-
-	switch strings.ToLower(*format) {
-	case "json":
-		enc := json.NewEncoder(out)
-		enc.SetIndent("", "  ")
-		if err := enc.Encode(segments); err != nil {
-			fmt.Fprintf(os.Stderr, "Error writing JSON: %v\n", err)
-			os.Exit(1)
-		}
-	case "srt":
-		for i, seg := range segments {
-			fmt.Fprintf(out, "%d\n%s --> %s\n%s\n\n",
-				i+1,
-				srtTimestamp(seg.Start),
-				srtTimestamp(seg.End),
-				seg.Text,
-			)
-		}
-	case "md", "markdown":
-		fmt.Fprintf(out, "# Transcript\n\n")
-		fmt.Fprintf(out, "| Time | Text |\n")
-		fmt.Fprintf(out, "|------|------|\n")
-		for _, seg := range segments {
-			fmt.Fprintf(out, "| %s → %s | %s |\n", seg.Start, seg.End, seg.Text)
-		}
-	default: // txt
-		for _, seg := range segments {
-			fmt.Fprintf(out, "[%s -> %s] %s\n", seg.Start, seg.End, seg.Text)
-		}
-	}
-
-	if *output != "" {
-		fmt.Fprintf(os.Stderr, "Output written to %s\n", *output)
+	dec, err := newAudioDecoder(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error converting audio: %v\n", err)
+		os.Exit(1)
 	}
-	fmt.Fprintf(os.Stderr, "Done.\n")
-}
-
-func srtTimestamp(ts string) string {
-	// Convert 00:00:00.000 to 00:00:00,000
-	return strings.Replace(ts, ".", ",", 1)
-}
-
-func segmentByVAD(samples []float32) ([]audioSegment, error) {
-	const (
-		sampleRate   = 16000
-		hopSize      = 256   // 16ms frames
-		threshold    = 0.5   // VAD onset sensitivity (higher = fewer false positives)
-		silenceGap   = 19    // ~300ms of silence to split (sampleRate * 0.3 / hopSize)
-		paddingSamps = 3200  // 200ms padding (sampleRate * 0.2)
-	)
-
-	vad, err := NewVad(hopSize, threshold)
+	pcm, err := newPCMReader(dec, mode)
 	if err != nil {
-		return nil, fmt.Errorf("create vad: %w", err)
+		fmt.Fprintf(os.Stderr, "Error converting audio: %v\n", err)
+		os.Exit(1)
 	}
-	defer vad.Close()
-
-	totalFrames := len(samples) / hopSize
-	frame := make([]int16, hopSize)
-
-	type rawSegment struct {
-		startFrame int
-		endFrame   int
+	var speakerNames []string
+	if *speakers != "" {
+		speakerNames = strings.Split(*speakers, ",")
 	}
+	trackLabels := trackNames(mode, pcm.Tracks(), speakerNames)
+	src := newTrimReader(pcm, int(fromSec*sampleRate), int(toSec*sampleRate))
 
-	var segments []rawSegment
-	inSpeech := false
-	speechStart := 0
-	silenceCount := 0
-
-	for f := 0; f < totalFrames; f++ {
-		off := f * hopSize
-		for i := 0; i < hopSize; i++ {
-			v := samples[off+i]
-			if v > 1.0 {
-				v = 1.0
-			} else if v < -1.0 {
-				v = -1.0
+	finishTimeline := func() {}
+	if *timelinePath != "" {
+		f, err := os.Create(*timelinePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating VAD timeline: %v\n", err)
+			os.Exit(1)
+		}
+		timeline := newVADTimeline(f, timelineFormat(*timelinePath))
+		vadOpts.Trace = timeline
+		finishTimeline = func() {
+			err := timeline.Close()
+			if cerr := f.Close(); err == nil {
+				err = cerr
 			}
-			frame[i] = int16(v * math.MaxInt16)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing VAD timeline: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Fprintf(os.Stderr, "VAD timeline written to %s\n", *timelinePath)
 		}
+	}
 
-		_, isSpeech, err := vad.Process(frame)
+	if *vadOnly {
+		fmt.Fprintf(os.Stderr, "Detecting speech...\n")
+		// intervals are the padded chunks; speech leaves out the padding and
+		// the silence bridged by -merge-gap, which would otherwise count as
+		// talk time in the stats
+		var intervals, speech []speechInterval
+		err = segmentStream(src, fromSec, vadOpts, func(chunk audioSegment) error {
+			intervals = append(intervals, speechInterval{
+				Start:   chunk.startSec,
+				End:     chunk.startSec + float64(len(chunk.samples))/sampleRate,
+				Speaker: trackLabels[chunk.track],
+			})
+			for _, sp := range chunk.speech {
+				speech = append(speech, speechInterval{
+					Start:   sp[0],
+					End:     sp[1],
+					Speaker: trackLabels[chunk.track],
+				})
+			}
+			return nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("vad process frame %d: %w", f, err)
+			fmt.Fprintf(os.Stderr, "Error in VAD segmentation: %v\n", err)
+			os.Exit(1)
 		}
+		finishTimeline()
+		sort.SliceStable(intervals, func(i, j int) bool { return intervals[i].Start < intervals[j].Start })
 
-		if isSpeech {
-			if !inSpeech {
-				speechStart = f
-				inSpeech = true
-			}
-			silenceCount = 0
-		} else if inSpeech {
-			silenceCount++
-			if silenceCount >= silenceGap {
-				segments = append(segments, rawSegment{speechStart, f - silenceCount})
-				inSpeech = false
-				silenceCount = 0
-			}
+		audioEnd := pcm.Duration()
+		if toSec > 0 {
+			audioEnd = min(audioEnd, toSec)
 		}
+		stats := computeSpeechStats(intervals, speech, trackLabels, max(audioEnd-fromSec, 0))
+		printSpeechStats(os.Stderr, stats)
+		writeOutput(*output, func(w io.Writer) error {
+			return writeIntervals(w, *format, intervals, stats)
+		})
+		fmt.Fprintf(os.Stderr, "Done.\n")
+		return
+	}
+
+	// Decoding, VAD and transcription are interleaved: each speech chunk is
+	// transcribed as soon as VAD closes it, so the whole file is never held
+	// in memory.
+	fmt.Fprintf(os.Stderr, "Transcribing speech segments...\n")
+	pool, err := newChunkPool(model, *workers, transcribeOptions{
+		Lang:      *lang,
+		Translate: *translate,
+		Prompt:    *prompt,
+		Threads:   max(*threads / *workers, 1),
+		Decoding:  decodingOpts,
+		Words:     *wordTimestamps,
+		Filter:    filterCfg,
+
+		CarryTokens: *carryTokens,
+	}, trackLabels)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up whisper: %v\n", err)
+		os.Exit(1)
 	}
-	if inSpeech {
-		segments = append(segments, rawSegment{speechStart, totalFrames - 1})
-	}
+	numChunks := 0
 
-	// If no speech detected, return empty (no hallucinations on silence)
-	if len(segments) == 0 {
-		return nil, nil
+	err = segmentStream(src, fromSec, vadOpts, func(chunk audioSegment) error {
+		numChunks++
+		return pool.Submit(chunk)
+	})
+	// A failed chunk also stops segmentation, so check the pool first
+	segments, poolErr := pool.Wait()
+	if poolErr != nil {
+		fmt.Fprintf(os.Stderr, "Error processing %v\n", poolErr)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in VAD segmentation: %v\n", err)
+		os.Exit(1)
 	}
+	finishTimeline()
+	fmt.Fprintf(os.Stderr, "Audio processed: %.1f seconds, %d speech chunk(s)\n", pcm.Duration(), numChunks)
 
-	result := make([]audioSegment, 0, len(segments))
-	for _, seg := range segments {
-		startSamp := seg.startFrame*hopSize - paddingSamps
-		if startSamp < 0 {
-			startSamp = 0
+	// Chunks of separate channels arrive in the order VAD closed them
+	sort.SliceStable(segments, func(i, j int) bool {
+		return parseDuration(segments[i].Start) < parseDuration(segments[j].Start)
+	})
+	segments = deduplicateSegments(segments)
+
+	dropped := pool.Dropped()
+	if filterCfg.enabled(ruleSegmentLoop) {
+		var looped []droppedSegment
+		segments, looped = collapseSegmentLoops(segments)
+		dropped = append(dropped, looped...)
+	}
+	loops := 0 // word loops are cut from segments that were kept
+	for _, d := range dropped {
+		if d.Verdict.Rule == ruleWordLoop {
+			loops++
 		}
-		endSamp := seg.endFrame*hopSize + hopSize + paddingSamps
-		if endSamp > len(samples) {
-			endSamp = len(samples)
+	}
+	fmt.Fprintf(os.Stderr, "Hallucination filter dropped %d segment(s), cut %d word loop(s)\n", len(dropped)-loops, loops)
+	if *filterReport != "" {
+		sort.SliceStable(dropped, func(i, j int) bool {
+			return parseDuration(dropped[i].Start) < parseDuration(dropped[j].Start)
+		})
+		reportFormat := "txt"
+		if strings.HasSuffix(strings.ToLower(*filterReport), ".json") {
+			reportFormat = "json"
 		}
-		result = append(result, audioSegment{
-			samples:  samples[startSamp:endSamp],
-			startSec: float64(startSamp) / sampleRate,
+		writeOutput(*filterReport, func(w io.Writer) error {
+			return writeFilterReport(w, reportFormat, dropped)
 		})
 	}
-	return result, nil
+
+	writeOutput(*output, func(w io.Writer) error {
+		return writeTranscript(w, *format, segments, *confidence)
+	})
+	fmt.Fprintf(os.Stderr, "Done.\n")
 }
 
-func convertToSamples(inputPath string) ([]float32, error) {
-	f, err := os.Open(inputPath)
-	if err != nil {
-		return nil, fmt.Errorf("open file: %w", err)
+// writeOutput runs write on the -output file, or stdout when path is empty,
+// and exits on failure.
+func writeOutput(path string, write func(io.Writer) error) {
+	out := os.Stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
 	}
-	defer f.Close()
 
-	d, err := mp3.NewDecoder(f)
-	if err != nil {
-		return nil, fmt.Errorf("decode mp3: %w", err)
+	if err := write(out); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
 	}
 
-	pcm, err := io.ReadAll(d)
-	if err != nil {
-		return nil, fmt.Errorf("read pcm: %w", err)
+	if path != "" {
+		fmt.Fprintf(os.Stderr, "Output written to %s\n", path)
 	}
+}
 
-	// go-mp3 outputs stereo int16 LE: each frame is 4 bytes [L_lo, L_hi, R_lo, R_hi]
-	numFrames := len(pcm) / 4
-	mono := make([]float32, numFrames)
-	for i := 0; i < numFrames; i++ {
-		l := int16(binary.LittleEndian.Uint16(pcm[i*4:]))
-		r := int16(binary.LittleEndian.Uint16(pcm[i*4+2:]))
-		mono[i] = (float32(l) + float32(r)) / (2 * 32768.0)
+// resolveModel returns the path of the model to load: modelPath if set,
+// otherwise the file for size, which is downloaded if missing. It exits on
+// failure.
+func resolveModel(modelPath, size string) string {
+	resolved := modelPath
+	if resolved == "" {
+		info, ok := modelSizes[size]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown model size %q. Available models:\n", size)
+			printModelList()
+			os.Exit(1)
+		}
+		resolved = filepath.Join(filepath.Dir(defaultModelPath), info.file)
 	}
 
-	// Resample from source rate to 16kHz
-	srcRate := d.SampleRate()
-	const dstRate = 16000
-	if srcRate == dstRate {
-		return mono, nil
+	if _, err := os.Stat(resolved); os.IsNotExist(err) {
+		if modelPath != "" {
+			fmt.Fprintf(os.Stderr, "Error: model not found at %s\n", resolved)
+			os.Exit(1)
+		}
+		info := modelSizes[size]
+		fmt.Fprintf(os.Stderr, "Model not found at %s\n", resolved)
+		fmt.Fprintf(os.Stderr, "Downloading %s (~%s)...\n", info.file, info.size)
+		if err := downloadModel(resolved, info.file); err != nil {
+			fmt.Fprintf(os.Stderr, "Error downloading model: %v\n", err)
+			os.Exit(1)
+		}
 	}
-	outLen := int(float64(len(mono))*float64(dstRate)/float64(srcRate)) + 256
-	out := make([]float32, outLen)
-	_, written := resampler.Resample32(mono, srcRate, out, dstRate, 4)
-	return out[:written], nil
+	return resolved
 }
 
 const modelBaseURL = "https://huggingface.co/ggerganov/whisper.cpp/resolve/main/"
@@ -419,6 +546,52 @@ func downloadModel(dest, filename string) error {
 	return os.Rename(tmp, dest)
 }
 
+// parseTimeArg parses a position in the input as plain seconds ("2520.5"),
+// a clock time ("42:00", "1:02:03.5") or a Go duration ("42m", "1h2m").
+// An empty string means zero.
+func parseTimeArg(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.IsNaN(secs) || math.IsInf(secs, 0) {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		if secs < 0 {
+			return 0, fmt.Errorf("negative time %q", s)
+		}
+		return secs, nil
+	}
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		var secs float64
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 || (i < len(parts)-1 && v != float64(int(v))) {
+				return 0, fmt.Errorf("invalid time %q", s)
+			}
+			// Only the leading field may exceed its unit
+			if i > 0 && v >= 60 {
+				return 0, fmt.Errorf("invalid time %q", s)
+			}
+			secs = secs*60 + v
+		}
+		return secs, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative time %q", s)
+	}
+	return d.Seconds(), nil
+}
+
 func formatDuration(d time.Duration) string {
 	total := d.Milliseconds()
 	if total < 0 {
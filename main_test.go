@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestParseTimeArg(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"2520", 2520, false},
+		{"12.5", 12.5, false},
+		{"42:00", 2520, false},
+		{"1:02:03.5", 3723.5, false},
+		{"42m", 2520, false},
+		{"1h2m3s", 3723, false},
+		{"-5", 0, true},
+		{"1:2:3:4", 0, true},
+		{"1.5:00", 0, true},
+		{"90:00", 5400, false},
+		{"1:60", 0, true},
+		{"1:75:00", 0, true},
+		{"1:00:60", 0, true},
+		{"NaN", 0, true},
+		{"Inf", 0, true},
+		{"-Inf", 0, true},
+		{"1:NaN", 0, true},
+		{"Inf:00", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseTimeArg(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeArg(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTimeArg(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
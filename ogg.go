@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jfreymuth/vorbis"
+)
+
+// oggReader demultiplexes the first logical bitstream of an Ogg file into
+// packets (RFC 3533). Pages of other multiplexed streams are skipped.
+type oggReader struct {
+	r       io.Reader
+	serial  uint32
+	started bool
+	lacing  []byte
+	body    []byte
+	seg     int   // next lacing value
+	off     int   // offset of the next segment in body
+	granule int64 // granule position of the current page
+	eos     bool  // the current page is the last of the stream
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: r}
+}
+
+// nextPacket returns the next complete packet, reassembling packets that
+// span several segments or pages.
+func (o *oggReader) nextPacket() ([]byte, error) {
+	var packet []byte
+	for {
+		for o.seg < len(o.lacing) {
+			n := int(o.lacing[o.seg])
+			o.seg++
+			packet = append(packet, o.body[o.off:o.off+n]...)
+			o.off += n
+			// A lacing value below 255 terminates the packet
+			if n < 255 {
+				return packet, nil
+			}
+		}
+		if err := o.readPage(); err != nil {
+			if err == io.EOF && len(packet) > 0 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+}
+
+func (o *oggReader) readPage() error {
+	for {
+		var hdr [27]byte
+		if _, err := io.ReadFull(o.r, hdr[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return fmt.Errorf("truncated page header: %w", err)
+			}
+			return err
+		}
+		if string(hdr[0:4]) != "OggS" {
+			return errors.New("missing OggS capture pattern")
+		}
+		if hdr[4] != 0 {
+			return fmt.Errorf("unsupported ogg version %d", hdr[4])
+		}
+		granule := int64(binary.LittleEndian.Uint64(hdr[6:14]))
+		serial := binary.LittleEndian.Uint32(hdr[14:18])
+
+		lacing := make([]byte, hdr[26])
+		if _, err := io.ReadFull(o.r, lacing); err != nil {
+			return fmt.Errorf("read lacing table: %w", err)
+		}
+		size := 0
+		for _, l := range lacing {
+			size += int(l)
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(o.r, body); err != nil {
+			return fmt.Errorf("read page body: %w", err)
+		}
+
+		// The checksum covers the whole page with the CRC field zeroed
+		want := binary.LittleEndian.Uint32(hdr[22:26])
+		binary.LittleEndian.PutUint32(hdr[22:26], 0)
+		crc := oggCRC(0, hdr[:])
+		crc = oggCRC(crc, lacing)
+		crc = oggCRC(crc, body)
+		if crc != want {
+			return fmt.Errorf("page checksum mismatch (stream %d)", serial)
+		}
+
+		if !o.started {
+			o.serial = serial
+			o.started = true
+		}
+		if serial != o.serial {
+			continue
+		}
+		o.lacing, o.body, o.seg, o.off = lacing, body, 0, 0
+		o.granule, o.eos = granule, hdr[5]&0x04 != 0
+		return nil
+	}
+}
+
+var oggCRCTable = func() [256]uint32 {
+	var t [256]uint32
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04C11DB7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return t
+}()
+
+// oggCRC is the unreflected CRC-32 (polynomial 0x04C11DB7) Ogg uses.
+func oggCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggCodec decodes audio packets into interleaved float32 samples.
+type oggCodec interface {
+	decode(packet []byte) ([]float32, error)
+	// length converts the final granule position into the number of
+	// samples per channel the whole stream decodes to.
+	length(granule int64) int64
+}
+
+// oggDecoder decodes Ogg/Vorbis and Ogg/Opus streams.
+type oggDecoder struct {
+	ogg        *oggReader
+	codec      oggCodec
+	sampleRate int
+	channels   int
+	pending    []float32 // decoded samples not yet returned by Read
+	decoded    int64     // samples per channel decoded so far
+}
+
+func newOggDecoder(r io.Reader) (*oggDecoder, error) {
+	o := newOggReader(r)
+	head, err := o.nextPacket()
+	if err != nil {
+		return nil, fmt.Errorf("read first packet: %w", err)
+	}
+
+	d := &oggDecoder{ogg: o}
+	switch {
+	case bytes.HasPrefix(head, []byte("OpusHead")):
+		err = d.initOpus(head)
+	case bytes.HasPrefix(head, []byte("\x01vorbis")):
+		err = d.initVorbis(head)
+	default:
+		return nil, errors.New("unsupported codec in ogg stream (want Opus or Vorbis)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *oggDecoder) SampleRate() int { return d.sampleRate }
+func (d *oggDecoder) Channels() int   { return d.channels }
+
+func (d *oggDecoder) Read(buf []float32) (int, error) {
+	n := len(buf) / d.channels * d.channels
+	out := 0
+	for out < n {
+		if len(d.pending) == 0 {
+			packet, err := d.ogg.nextPacket()
+			if err != nil {
+				return out, err
+			}
+			if d.pending, err = d.codec.decode(packet); err != nil {
+				return out, err
+			}
+			d.trimEnd()
+			continue
+		}
+		c := copy(buf[out:n], d.pending)
+		d.pending = d.pending[c:]
+		out += c
+	}
+	return out, nil
+}
+
+// trimEnd drops the encoder padding from the last page. Its granule
+// position gives the exact stream length, which usually is not a whole
+// number of frames.
+func (d *oggDecoder) trimEnd() {
+	d.decoded += int64(len(d.pending) / d.channels)
+	if !d.ogg.eos || d.ogg.granule < 0 {
+		return
+	}
+	over := d.decoded - d.codec.length(d.ogg.granule)
+	if over <= 0 {
+		return
+	}
+	keep := int64(len(d.pending)/d.channels) - over
+	if keep < 0 {
+		keep = 0
+	}
+	d.pending = d.pending[:keep*int64(d.channels)]
+	d.decoded -= over
+}
+
+type vorbisCodec struct {
+	d *vorbis.Decoder
+}
+
+func (d *oggDecoder) initVorbis(ident []byte) error {
+	v := &vorbis.Decoder{}
+	if err := v.ReadHeader(ident); err != nil {
+		return fmt.Errorf("vorbis identification header: %w", err)
+	}
+	// Comment and setup headers follow the identification header
+	for !v.HeadersRead() {
+		packet, err := d.ogg.nextPacket()
+		if err != nil {
+			return fmt.Errorf("read vorbis header: %w", err)
+		}
+		if err := v.ReadHeader(packet); err != nil {
+			return fmt.Errorf("vorbis header: %w", err)
+		}
+	}
+	d.codec = &vorbisCodec{d: v}
+	d.sampleRate = v.SampleRate()
+	d.channels = v.Channels()
+	return nil
+}
+
+func (c *vorbisCodec) decode(packet []byte) ([]float32, error) {
+	return c.d.Decode(packet)
+}
+
+// The Vorbis granule position counts samples at the stream's own rate.
+func (c *vorbisCodec) length(granule int64) int64 { return granule }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// oggPage builds one Ogg page from a lacing table and the matching body.
+// Flag 0x01 marks a continued packet, 0x02 the first page, 0x04 the last.
+func oggPage(serial, seq uint32, flags byte, granule int64, lacing []byte, body []byte) []byte {
+	var p bytes.Buffer
+	p.WriteString("OggS")
+	p.WriteByte(0) // version
+	p.WriteByte(flags)
+	binary.Write(&p, binary.LittleEndian, granule)
+	binary.Write(&p, binary.LittleEndian, serial)
+	binary.Write(&p, binary.LittleEndian, seq)
+	binary.Write(&p, binary.LittleEndian, uint32(0)) // CRC placeholder
+	p.WriteByte(byte(len(lacing)))
+	p.Write(lacing)
+	p.Write(body)
+
+	page := p.Bytes()
+	binary.LittleEndian.PutUint32(page[22:26], oggCRC(0, page))
+	return page
+}
+
+// oggLacing returns the lacing values for a complete packet of size n.
+func oggLacing(n int) []byte {
+	var l []byte
+	for n >= 255 {
+		l = append(l, 255)
+		n -= 255
+	}
+	return append(l, byte(n))
+}
+
+func TestOggReaderPackets(t *testing.T) {
+	small := []byte("hello")
+	exact := bytes.Repeat([]byte{'x'}, 255) // needs a terminating 0 lacing value
+	large := bytes.Repeat([]byte{'y'}, 700) // spans two pages
+
+	var stream bytes.Buffer
+	// Page 1: small packet, exact packet, and the first 510 bytes of large
+	lacing := append(oggLacing(len(small)), oggLacing(len(exact))...)
+	lacing = append(lacing, 255, 255)
+	body := append(append(append([]byte{}, small...), exact...), large[:510]...)
+	stream.Write(oggPage(1, 0, 0x02, 0, lacing, body))
+	// A page from another multiplexed stream must be ignored
+	stream.Write(oggPage(2, 0, 0x02, 0, oggLacing(3), []byte("zzz")))
+	// Page 2: rest of large, continued packet
+	stream.Write(oggPage(1, 1, 0x01, 0, oggLacing(190), large[510:]))
+
+	o := newOggReader(&stream)
+	for i, want := range [][]byte{small, exact, large} {
+		got, err := o.nextPacket()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("packet %d: got %d bytes, want %d", i, len(got), len(want))
+		}
+	}
+	if _, err := o.nextPacket(); err != io.EOF {
+		t.Fatalf("after last packet: got %v, want io.EOF", err)
+	}
+}
+
+func TestOggReaderErrors(t *testing.T) {
+	good := oggPage(1, 0, 0x02, 0, oggLacing(5), []byte("hello"))
+
+	corrupt := append([]byte{}, good...)
+	corrupt[len(corrupt)-1] ^= 0xFF
+
+	truncated := oggPage(1, 0, 0x02, 0, []byte{255}, bytes.Repeat([]byte{'a'}, 255))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"bad checksum", corrupt},
+		{"not ogg", []byte("RIFF0000WAVEfmt ........................")},
+		{"packet cut off at end of stream", truncated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newOggReader(bytes.NewReader(tt.data)).nextPacket(); err == nil || err == io.EOF {
+				t.Errorf("got %v, want a decode error", err)
+			}
+		})
+	}
+}
+
+func TestOggDecoderRejectsUnknownCodec(t *testing.T) {
+	page := oggPage(1, 0, 0x02, 0, oggLacing(8), []byte("\x80theora!"))
+	if _, err := newAudioDecoder(bytes.NewReader(page)); err == nil {
+		t.Fatal("expected error for non-audio ogg stream")
+	}
+}
+
+// opusStream builds a stereo Ogg/Opus stream with 80ms of pre-skip and ten
+// 20ms packets, ending at the given granule position.
+func opusStream(granule int64) *bytes.Buffer {
+	head := []byte("OpusHead")
+	head = append(head, 1, 2)                            // version, stereo
+	head = binary.LittleEndian.AppendUint16(head, 3840)  // pre-skip: 80ms at 48 kHz
+	head = binary.LittleEndian.AppendUint32(head, 48000) // input rate
+	head = binary.LittleEndian.AppendUint16(head, 0)     // gain
+	head = append(head, 0)                               // mapping family
+	tags := []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00")
+
+	var stream bytes.Buffer
+	stream.Write(oggPage(7, 0, 0x02, 0, oggLacing(len(head)), head))
+	stream.Write(oggPage(7, 1, 0, 0, oggLacing(len(tags)), tags))
+	// Ten 20ms packets with an empty frame (TOC byte 0xF8: CELT FB 20ms),
+	// which libopus decodes as packet loss concealment
+	var lacing, body []byte
+	for i := 0; i < 10; i++ {
+		lacing = append(lacing, 1)
+		body = append(body, 0xF8)
+	}
+	stream.Write(oggPage(7, 2, 0x04, granule, lacing, body))
+	return &stream
+}
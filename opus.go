@@ -0,0 +1,92 @@
+//go:build !noopus
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/hraban/opus"
+)
+
+// Opus is decoded straight to 16 kHz, which libopus supports natively.
+const opusDecodeRate = 16000
+
+type opusCodec struct {
+	d        *opus.Decoder
+	channels int
+	pcm      []float32
+	skip     int     // samples per channel still to drop (pre-skip)
+	preSkip  int64   // pre-skip in 48 kHz samples, as in the header
+	gain     float32 // output gain from the header, linear
+}
+
+func (d *oggDecoder) initOpus(head []byte) error {
+	// OpusHead: magic(8) version(1) channels(1) pre-skip(2) input rate(4)
+	// output gain(2) mapping family(1), all little-endian (RFC 7845)
+	if len(head) < 19 {
+		return errors.New("opus header too short")
+	}
+	if head[8]>>4 != 0 {
+		return fmt.Errorf("unsupported opus header version %d", head[8])
+	}
+	channels := int(head[9])
+	preSkip := int(binary.LittleEndian.Uint16(head[10:12]))
+	gainQ8 := int16(binary.LittleEndian.Uint16(head[16:18]))
+	if family := head[18]; family != 0 {
+		return fmt.Errorf("unsupported opus channel mapping family %d", family)
+	}
+	if channels < 1 || channels > 2 {
+		return fmt.Errorf("invalid opus channel count %d", channels)
+	}
+
+	// OpusTags must follow; it carries nothing we need
+	if _, err := d.ogg.nextPacket(); err != nil {
+		return fmt.Errorf("read opus tags: %w", err)
+	}
+
+	dec, err := opus.NewDecoder(opusDecodeRate, channels)
+	if err != nil {
+		return fmt.Errorf("create opus decoder: %w", err)
+	}
+	d.codec = &opusCodec{
+		d:        dec,
+		channels: channels,
+		pcm:      make([]float32, opusDecodeRate*120/1000*channels), // max 120ms packet
+		skip:     preSkip * opusDecodeRate / 48000,                  // pre-skip is in 48 kHz samples
+		preSkip:  int64(preSkip),
+		gain:     float32(math.Pow(10, float64(gainQ8)/(20*256))),
+	}
+	d.sampleRate = opusDecodeRate
+	d.channels = channels
+	return nil
+}
+
+func (c *opusCodec) decode(packet []byte) ([]float32, error) {
+	n, err := c.d.DecodeFloat32(packet, c.pcm)
+	if err != nil {
+		return nil, fmt.Errorf("decode opus packet: %w", err)
+	}
+	out := c.pcm[:n*c.channels]
+	if c.skip > 0 {
+		drop := c.skip
+		if drop > n {
+			drop = n
+		}
+		c.skip -= drop
+		out = out[drop*c.channels:]
+	}
+	if c.gain != 1 {
+		for i := range out {
+			out[i] *= c.gain
+		}
+	}
+	return out, nil
+}
+
+// The Opus granule position counts 48 kHz samples including the pre-skip.
+func (c *opusCodec) length(granule int64) int64 {
+	return (granule - c.preSkip) * opusDecodeRate / 48000
+}
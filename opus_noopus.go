@@ -0,0 +1,11 @@
+//go:build noopus
+
+package main
+
+import "errors"
+
+// initOpus stands in for the libopus decoder in builds without it, so
+// Ogg/Opus input is refused while Ogg/Vorbis still works.
+func (d *oggDecoder) initOpus(head []byte) error {
+	return errors.New("built without Opus support (noopus tag)")
+}
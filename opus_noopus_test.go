@@ -0,0 +1,18 @@
+//go:build noopus
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// opusBuilt tells shared tests whether Ogg/Opus input can be decoded.
+const opusBuilt = false
+
+func TestOggOpusUnsupported(t *testing.T) {
+	_, err := newAudioDecoder(opusStream(9600))
+	if err == nil || !strings.Contains(err.Error(), "noopus") {
+		t.Errorf("err = %v, want a noopus build error", err)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !noopus
+
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+// opusBuilt tells shared tests whether Ogg/Opus input can be decoded.
+const opusBuilt = true
+
+// decodeAll reads a decoder to the end and returns the number of samples.
+func decodeAll(t *testing.T, d audioDecoder) int {
+	t.Helper()
+	total := 0
+	buf := make([]float32, 1000)
+	for {
+		n, err := d.Read(buf)
+		total += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	return total
+}
+
+func TestOggOpusPreSkip(t *testing.T) {
+	// 200ms at 48 kHz: every decoded sample belongs to the stream
+	d, err := newAudioDecoder(opusStream(9600))
+	if err != nil {
+		t.Fatalf("newAudioDecoder: %v", err)
+	}
+	if d.SampleRate() != 16000 || d.Channels() != 2 {
+		t.Fatalf("got %d ch @ %d Hz, want 2 ch @ 16000 Hz", d.Channels(), d.SampleRate())
+	}
+	total := decodeAll(t, d)
+	// 200ms decoded minus 80ms pre-skip = 120ms = 1920 frames at 16 kHz
+	if want := 1920 * 2; total != want {
+		t.Errorf("got %d samples, want %d", total, want)
+	}
+}
+
+func TestOggOpusEndTrim(t *testing.T) {
+	// The stream ends 7.5ms into the last packet; the rest is padding
+	d, err := newAudioDecoder(opusStream(9000))
+	if err != nil {
+		t.Fatalf("newAudioDecoder: %v", err)
+	}
+	// 9000 - 3840 pre-skip = 5160 samples at 48 kHz = 1720 frames at 16 kHz
+	if got, want := decodeAll(t, d), 1720*2; got != want {
+		t.Errorf("got %d samples, want %d", got, want)
+	}
+}
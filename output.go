@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeTranscript renders segments in the given format: txt, json, srt or
+// md. Segments with a speaker are labelled in every format. json always
+// carries the confidence scores; the other formats add them with scores.
+func writeTranscript(out io.Writer, format string, segments []transcriptSegment, scores bool) error {
+	switch strings.ToLower(format) {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(segments)
+	case "srt":
+		for i, seg := range segments {
+			text := speakerText(seg)
+			if scores {
+				text += "\n[" + seg.summary() + "]"
+			}
+			fmt.Fprintf(out, "%d\n%s --> %s\n%s\n\n",
+				i+1,
+				srtTimestamp(seg.Start),
+				srtTimestamp(seg.End),
+				text,
+			)
+		}
+	case "md", "markdown":
+		withSpeaker := hasSpeakers(segments)
+		fmt.Fprintf(out, "# Transcript\n\n")
+		header, rule := "| Time |", "|------|"
+		if withSpeaker {
+			header, rule = header+" Speaker |", rule+"---------|"
+		}
+		header, rule = header+" Text |", rule+"------|"
+		if scores {
+			header += " Logprob | No speech | Compression | Min p |"
+			rule += "---------|-----------|-------------|-------|"
+		}
+		fmt.Fprintf(out, "%s\n%s\n", header, rule)
+		for _, seg := range segments {
+			row := fmt.Sprintf("| %s → %s |", seg.Start, seg.End)
+			if withSpeaker {
+				row += fmt.Sprintf(" %s |", seg.Speaker)
+			}
+			row += fmt.Sprintf(" %s |", seg.Text)
+			if scores {
+				row += fmt.Sprintf(" %.2f | %.2f | %.2f | %.2f |", seg.AvgLogprob, seg.NoSpeechProb, seg.CompressionRatio, seg.MinTokenProb)
+			}
+			fmt.Fprintln(out, row)
+		}
+	default: // txt
+		for _, seg := range segments {
+			if scores {
+				fmt.Fprintf(out, "[%s -> %s] [%s] %s\n", seg.Start, seg.End, seg.summary(), speakerText(seg))
+			} else {
+				fmt.Fprintf(out, "[%s -> %s] %s\n", seg.Start, seg.End, speakerText(seg))
+			}
+		}
+	}
+	return nil
+}
+
+func srtTimestamp(ts string) string {
+	// Convert 00:00:00.000 to 00:00:00,000
+	return strings.Replace(ts, ".", ",", 1)
+}
+
+// speakerText prefixes the text with the speaker label, if any.
+func speakerText(seg transcriptSegment) string {
+	if seg.Speaker == "" {
+		return seg.Text
+	}
+	return seg.Speaker + ": " + seg.Text
+}
+
+func hasSpeakers(segments []transcriptSegment) bool {
+	for _, seg := range segments {
+		if seg.Speaker != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFilterReport lists the segments the hallucination filter dropped, as
+// json or txt, for auditing the filter on real speech.
+func writeFilterReport(out io.Writer, format string, dropped []droppedSegment) error {
+	if format == "json" {
+		if dropped == nil {
+			dropped = []droppedSegment{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(dropped)
+	}
+	for _, d := range dropped {
+		fmt.Fprintf(out, "[%s -> %s] %s: %s\n", d.Start, d.End, d.Verdict, speakerText(d.transcriptSegment))
+	}
+	return nil
+}
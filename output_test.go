@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTranscriptSpeakers(t *testing.T) {
+	segments := []transcriptSegment{
+		{Start: "00:00:01.000", End: "00:00:02.500", Speaker: "agent", Text: "How can I help?"},
+		{Start: "00:00:02.000", End: "00:00:04.000", Speaker: "customer", Text: "My order is late."},
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"txt", []string{
+			"[00:00:01.000 -> 00:00:02.500] agent: How can I help?",
+			"[00:00:02.000 -> 00:00:04.000] customer: My order is late.",
+		}},
+		{"srt", []string{"00:00:01,000 --> 00:00:02,500\nagent: How can I help?"}},
+		{"md", []string{"| Time | Speaker | Text |", "| 00:00:02.000 → 00:00:04.000 | customer | My order is late. |"}},
+		{"json", []string{`"speaker": "agent"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeTranscript(&buf, tt.format, segments, false); err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(buf.String(), w) {
+					t.Errorf("output missing %q:\n%s", w, buf.String())
+				}
+			}
+		})
+	}
+
+	// Without speakers the output is unchanged
+	var buf bytes.Buffer
+	writeTranscript(&buf, "json", []transcriptSegment{{Start: "00:00:00.000", End: "00:00:01.000", Text: "Hi"}}, false)
+	if strings.Contains(buf.String(), "speaker") {
+		t.Errorf("unexpected speaker field:\n%s", buf.String())
+	}
+}
+
+func TestWriteTranscriptScores(t *testing.T) {
+	segments := []transcriptSegment{{
+		Start: "00:00:01.000", End: "00:00:02.500", Text: "How can I help?",
+		segmentScores: segmentScores{AvgLogprob: -0.3124, NoSpeechProb: 0.02, CompressionRatio: 1.1, MinTokenProb: 0.15},
+	}}
+
+	tests := []struct {
+		format string
+		scores bool
+		want   []string
+	}{
+		{"json", false, []string{`"avg_logprob": -0.3124`, `"no_speech_prob": 0.02`, `"compression_ratio": 1.1`, `"min_token_prob": 0.15`}},
+		{"txt", true, []string{"[00:00:01.000 -> 00:00:02.500] [logprob -0.31, no-speech 0.02, compression 1.10, min-p 0.15] How can I help?"}},
+		{"srt", true, []string{"How can I help?\n[logprob -0.31, no-speech 0.02, compression 1.10, min-p 0.15]\n"}},
+		{"md", true, []string{"| Time | Text | Logprob | No speech | Compression | Min p |", "| How can I help? | -0.31 | 0.02 | 1.10 | 0.15 |"}},
+		{"txt", false, []string{"[00:00:01.000 -> 00:00:02.500] How can I help?\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeTranscript(&buf, tt.format, segments, tt.scores); err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(buf.String(), w) {
+					t.Errorf("output missing %q:\n%s", w, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFilterReport(t *testing.T) {
+	dropped := []droppedSegment{
+		{
+			transcriptSegment: transcriptSegment{Start: "00:00:04.000", End: "00:00:05.000", Speaker: "agent", Text: "Thank you."},
+			Verdict:           filterVerdict{Rule: rulePhrase, Match: "thank you."},
+		},
+		{
+			transcriptSegment: transcriptSegment{Start: "00:00:09.000", End: "00:00:12.000", Text: "mumbled words here", segmentScores: segmentScores{AvgLogprob: -1.4321}},
+			Verdict:           filterVerdict{Rule: ruleLogprob, Value: -1.4321, Threshold: -1},
+		},
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"txt", []string{
+			`[00:00:04.000 -> 00:00:05.000] known_phrase "thank you.": agent: Thank you.`,
+			"[00:00:09.000 -> 00:00:12.000] avg_logprob -1.432 (threshold -1): mumbled words here",
+		}},
+		{"json", []string{`"rule": "known_phrase"`, `"match": "thank you."`, `"avg_logprob": -1.4321`, `"threshold": -1`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := writeFilterReport(&buf, tt.format, dropped); err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(buf.String(), w) {
+					t.Errorf("report missing %q:\n%s", w, buf.String())
+				}
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	writeFilterReport(&buf, "json", nil)
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("empty report = %q, want []", got)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// phraseList holds known hallucinations: whole segments (phrases), segment
+// starts (prefixes) and regular expressions (patterns), either for every
+// language or for one.
+type phraseList struct {
+	sets map[string]*phraseSet // by language code; "" applies to all
+}
+
+type phraseSet struct {
+	phrases  map[string]struct{} // lowercase, trimmed
+	prefixes []string            // lowercase
+	patterns []*regexp.Regexp
+}
+
+// builtinPhrases is the compiled-in list, for every language.
+var builtinPhrases = newBuiltinPhraseList()
+
+func newPhraseList() *phraseList {
+	return &phraseList{sets: map[string]*phraseSet{}}
+}
+
+func newBuiltinPhraseList() *phraseList {
+	l := newPhraseList()
+	set := l.set("")
+	for p := range hallucinationPhrases {
+		set.phrases[p] = struct{}{}
+	}
+	set.prefixes = append(set.prefixes, hallucinationPrefixes...)
+	return l
+}
+
+// set returns the set for lang, creating it.
+func (l *phraseList) set(lang string) *phraseSet {
+	s, ok := l.sets[lang]
+	if !ok {
+		s = &phraseSet{phrases: map[string]struct{}{}}
+		l.sets[lang] = s
+	}
+	return s
+}
+
+// load adds the entries of a list file. Entries go one per line under a
+// section header naming their kind, optionally for one language only:
+//
+//	# hold music
+//	[phrases]
+//	thank you for holding.
+//	[prefixes:uk]
+//	музика
+//	[patterns]
+//	^(la ?)+\.?$
+//
+// Phrases and prefixes are matched ignoring case; patterns are matched
+// against the trimmed text as is, so use (?i) to ignore case.
+func (l *phraseList) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var kind string
+	var set *phraseSet
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section := strings.ToLower(line[1 : len(line)-1])
+			var lang string
+			kind, lang, _ = strings.Cut(section, ":")
+			if kind != "phrases" && kind != "prefixes" && kind != "patterns" {
+				return fmt.Errorf("%s:%d: unknown section %q (want phrases, prefixes or patterns, optionally with :lang)", path, n, line)
+			}
+			set = l.set(lang)
+			continue
+		}
+		switch kind {
+		case "":
+			return fmt.Errorf("%s:%d: entry before the first section header", path, n)
+		case "phrases":
+			set.phrases[strings.ToLower(line)] = struct{}{}
+		case "prefixes":
+			set.prefixes = append(set.prefixes, strings.ToLower(line))
+		case "patterns":
+			re, err := regexp.Compile(line)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %w", path, n, err)
+			}
+			set.patterns = append(set.patterns, re)
+		}
+	}
+	return scanner.Err()
+}
+
+// forLang returns the sets that apply to text in lang.
+func (l *phraseList) forLang(lang string) []*phraseSet {
+	var sets []*phraseSet
+	if s, ok := l.sets[""]; ok {
+		sets = append(sets, s)
+	}
+	if s, ok := l.sets[lang]; ok && lang != "" {
+		sets = append(sets, s)
+	}
+	return sets
+}
+
+// phrase returns the normalized text if it is a known hallucination in lang.
+func (l *phraseList) phrase(text, lang string) (string, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(text))
+	for _, s := range l.forLang(lang) {
+		if _, found := s.phrases[normalized]; found {
+			return normalized, true
+		}
+	}
+	return "", false
+}
+
+// prefix returns the known hallucination prefix in lang that text starts
+// with.
+func (l *phraseList) prefix(text, lang string) (string, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(text))
+	for _, s := range l.forLang(lang) {
+		for _, prefix := range s.prefixes {
+			if strings.HasPrefix(normalized, prefix) {
+				return prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// pattern returns the first pattern in lang that matches text.
+func (l *phraseList) pattern(text, lang string) (string, bool) {
+	text = strings.TrimSpace(text)
+	for _, s := range l.forLang(lang) {
+		for _, re := range s.patterns {
+			if re.MatchString(text) {
+				return re.String(), true
+			}
+		}
+	}
+	return "", false
+}
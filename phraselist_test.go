@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+const testPhraseList = `# Hold music and IVR artifacts
+[phrases]
+Thank you for holding.
+
+[prefixes]
+your call is important
+
+[patterns]
+^(?i)((la)+[ ,.]*)+$
+
+[phrases:uk]
+музика грає.
+`
+
+func writeList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "list.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPhraseList(t *testing.T) {
+	cfg := defaultFilterConfig()
+	cfg.Lists = []string{writeList(t, testPhraseList)}
+	if err := cfg.loadLists(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text, lang string
+		want       filterVerdict
+	}{
+		{"Thank you for holding.", "en", filterVerdict{Rule: rulePhrase, Match: "thank you for holding."}},
+		{"Your call is important to us, please stay on the line.", "", filterVerdict{Rule: rulePrefix, Match: "your call is important"}},
+		{" Lalala, lalala, lalala.", "en", filterVerdict{Rule: rulePattern, Match: `^(?i)((la)+[ ,.]*)+$`}},
+		{"Музика грає.", "uk", filterVerdict{Rule: rulePhrase, Match: "музика грає."}},
+		{"Музика грає.", "ru", filterVerdict{}},                                                        // other language
+		{"Музика грає.", "", filterVerdict{}},                                                          // language unknown
+		{"Thanks for watching!", "en", filterVerdict{Rule: rulePhrase, Match: "thanks for watching!"}}, // built-in kept
+		{"The llama ate the grass.", "en", filterVerdict{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text+"/"+tt.lang, func(t *testing.T) {
+			segment := whisper.Segment{Text: tt.text, Tokens: []whisper.Token{{P: 0.9}}}
+			if got := filterSegment(segment, cfg, tt.lang); got != tt.want {
+				t.Errorf("filterSegment() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("replace built-in", func(t *testing.T) {
+		cfg := cfg
+		cfg.ReplaceBuiltin = true
+		if err := cfg.loadLists(); err != nil {
+			t.Fatal(err)
+		}
+		if _, found := cfg.phraseList().phrase("Thanks for watching!", "en"); found {
+			t.Error("built-in phrase matched after replacing the list")
+		}
+		if _, found := cfg.phraseList().phrase("Thank you for holding.", "en"); !found {
+			t.Error("phrase from the file not matched")
+		}
+		if _, found := builtinPhrases.phrase("Thanks for watching!", "en"); !found {
+			t.Error("replacing changed the built-in list itself")
+		}
+	})
+}
+
+func TestPhraseListErrors(t *testing.T) {
+	tests := map[string]string{
+		"entry before section": "thank you.\n",
+		"unknown section":      "[words]\nthank you.\n",
+		"bad pattern":          "[patterns]\n(unclosed\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := newPhraseList().load(writeList(t, content))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), "list.txt:") {
+				t.Errorf("error %q does not name the file and line", err)
+			}
+		})
+	}
+}
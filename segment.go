@@ -0,0 +1,566 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+type audioSegment struct {
+	samples  []float32
+	startSec float64
+	track    int // index of the pcmReader track the chunk came from
+	// speech lists the detected speech inside the chunk as [start, end)
+	// pairs in seconds, without the padding around it or the silence
+	// bridged by merging
+	speech [][2]float64
+}
+
+const (
+	sampleRate = 16000
+	hopSize    = 256 // 16ms frames
+)
+
+// VADBackend detects speech in fixed-size frames of hopSize samples.
+type VADBackend interface {
+	// Process returns the probability, between 0 and 1, that frame holds
+	// speech. Backends may keep state between frames.
+	Process(frame []int16) (float32, error)
+	Close()
+}
+
+// vadBackends lists the values accepted for VADOptions.Backend.
+var vadBackends = []string{"ten", "energy"}
+
+// newVADBackend creates a backend by name: "ten" for ten-vad, "energy" for
+// the pure-Go energyVad.
+func newVADBackend(name string, threshold float32) (VADBackend, error) {
+	switch name {
+	case "ten":
+		return NewVad(hopSize, threshold)
+	case "energy":
+		return newEnergyVad(hopSize), nil
+	}
+	return nil, fmt.Errorf("unknown vad backend %q (want %s)", name, strings.Join(vadBackends, " or "))
+}
+
+// VADOptions tunes speech detection and chunking. Noisy recordings usually
+// want a higher Threshold and MinSpeech; clean audio with short pauses a
+// shorter MinSilence.
+type VADOptions struct {
+	Backend    string        // "ten" or "energy", see newVADBackend
+	Threshold  float32       // speech probability for a frame to count as speech (higher = fewer false positives)
+	MinSilence time.Duration // silence needed to close a chunk
+	Padding    time.Duration // audio kept before and after each chunk
+	MinSpeech  time.Duration // chunks with less speech than this are dropped
+	MaxChunk   time.Duration // longer speech is split at the quietest frame; 0 means no limit
+
+	// Neighbouring chunks separated by at most MergeGap of silence are
+	// joined while the result spans at most MergeTarget. A MergeTarget of
+	// 0 disables merging.
+	MergeGap    time.Duration
+	MergeTarget time.Duration
+
+	// Trace, if set, sees every frame's probability and every chunk, for
+	// debugging segmentation.
+	Trace vadTracer
+}
+
+// vadTracer observes segmentation. Times are in seconds from the start of
+// the input.
+type vadTracer interface {
+	// Frame reports the probability of the frame starting at time and
+	// whether it counted as speech.
+	Frame(track int, time float64, prob float32, speech bool)
+	// Chunk reports an emitted chunk, padding included.
+	Chunk(track int, start, end float64)
+}
+
+func defaultVADOptions() VADOptions {
+	return VADOptions{
+		Backend:    "ten",
+		Threshold:  0.5,
+		MinSilence: 300 * time.Millisecond,
+		Padding:    200 * time.Millisecond,
+		MergeGap:   time.Second,
+	}
+}
+
+func (o VADOptions) validate() error {
+	switch {
+	case !slices.Contains(vadBackends, o.Backend):
+		return fmt.Errorf("unknown vad backend %q (want %s)", o.Backend, strings.Join(vadBackends, " or "))
+	case o.Threshold <= 0 || o.Threshold >= 1:
+		return fmt.Errorf("vad threshold must be between 0 and 1, got %g", o.Threshold)
+	case o.MinSilence < hopSize*time.Second/sampleRate:
+		return fmt.Errorf("vad min silence must be at least %v, got %v", hopSize*time.Second/sampleRate, o.MinSilence)
+	case o.Padding < 0:
+		return fmt.Errorf("vad padding must not be negative, got %v", o.Padding)
+	case o.MinSpeech < 0:
+		return fmt.Errorf("vad min speech must not be negative, got %v", o.MinSpeech)
+	case o.MaxChunk != 0 && o.MaxChunk < time.Second:
+		return fmt.Errorf("max chunk duration must be at least 1s, got %v", o.MaxChunk)
+	case o.MergeGap < 0:
+		return fmt.Errorf("merge gap must not be negative, got %v", o.MergeGap)
+	case o.MergeTarget < 0:
+		return fmt.Errorf("merge target must not be negative, got %v", o.MergeTarget)
+	case o.MaxChunk != 0 && o.MergeTarget > o.MaxChunk:
+		return fmt.Errorf("merge target %v exceeds max chunk duration %v", o.MergeTarget, o.MaxChunk)
+	}
+	return nil
+}
+
+// durationFrames converts d to a whole number of VAD frames, rounding up.
+func durationFrames(d time.Duration) int {
+	const frame = hopSize * time.Second
+	return int((d*sampleRate + frame - 1) / frame)
+}
+
+// durationSamples converts d to a number of samples at sampleRate.
+func durationSamples(d time.Duration) int {
+	return int(d * sampleRate / time.Second)
+}
+
+// sampleReader yields one or more 16 kHz mono tracks in lockstep: Read
+// fills every buffer with the same number of samples.
+type sampleReader interface {
+	Tracks() int
+	Read(bufs [][]float32) (int, error)
+}
+
+// segmentByVAD splits an in-memory signal into speech chunks.
+func segmentByVAD(samples []float32, opts VADOptions) ([]audioSegment, error) {
+	var result []audioSegment
+	err := segmentStream(&sliceReader{samples: samples}, 0, opts, func(seg audioSegment) error {
+		result = append(result, seg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// segmentStream runs VAD over r block by block and calls emit for every
+// speech chunk as soon as it is complete. Only the samples that may still
+// end up in a chunk are kept, so memory is bounded by the longest speech
+// region rather than by the length of the input. Each track gets its own
+// VAD; chunks of different tracks are emitted in the order they close.
+//
+// offset is the position of r's first sample in the original input, in
+// seconds; it is added to every chunk's startSec so timestamps stay
+// absolute when the input was trimmed.
+func segmentStream(r sampleReader, offset float64, opts VADOptions, emit func(audioSegment) error) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	tracks := r.Tracks()
+	segmenters := make([]*vadSegmenter, tracks)
+	blocks := make([][]float32, tracks)
+	for t := range segmenters {
+		vad, err := newVADBackend(opts.Backend, opts.Threshold)
+		if err != nil {
+			return fmt.Errorf("create vad: %w", err)
+		}
+		defer vad.Close()
+		segmenters[t] = newVADSegmenter(vad, opts, t, offset, emit)
+		blocks[t] = make([]float32, 64*hopSize)
+	}
+
+	for {
+		n, readErr := r.Read(blocks)
+		if n > 0 {
+			for t, s := range segmenters {
+				if err := s.write(blocks[t][:n]); err != nil {
+					return err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	for _, s := range segmenters {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vadSegmenter is the incremental state of segmentStream. Frame and sample
+// positions are absolute, counted from the start of the stream.
+type vadSegmenter struct {
+	vad       VADBackend
+	threshold float32
+	trace     vadTracer
+	emit      func(audioSegment) error
+	track     int
+	offset    float64 // seconds added to startSec
+	frame     []int16
+
+	silenceGap int // silent frames that close a region
+	minSpeech  int // shortest region, in frames, that is emitted
+	padding    int // samples added on each side of a region
+	maxFrames  int // longest region before it is split; 0 means no limit
+	mergeGap   int // longest silence, in frames, between regions that are merged
+	mergeSpan  int // longest merged region in frames; 0 disables merging
+
+	buf      []float32 // retained samples; buf[0] is sample bufStart
+	bufStart int
+	frames   int // frames processed so far
+
+	inSpeech     bool
+	speechStart  int
+	silenceCount int
+	continued    bool      // the open region continues one that was split
+	probs        []float32 // VAD probability of each frame since speechStart
+
+	pending []speechRegion // closed regions waiting for their trailing padding
+	bridged [][2]int       // silent frames [start, end] inside pending regions, bridged by merging
+}
+
+// speechRegion is a closed range of speech frames, [start, end]. Edges
+// created by a forced split are not padded, so the audio around the split
+// point is not transcribed twice.
+type speechRegion struct {
+	start, end       int
+	padStart, padEnd bool
+}
+
+func newVADSegmenter(vad VADBackend, opts VADOptions, track int, offset float64, emit func(audioSegment) error) *vadSegmenter {
+	return &vadSegmenter{
+		vad:        vad,
+		threshold:  opts.Threshold,
+		trace:      opts.Trace,
+		emit:       emit,
+		track:      track,
+		offset:     offset,
+		frame:      make([]int16, hopSize),
+		silenceGap: durationFrames(opts.MinSilence),
+		minSpeech:  durationFrames(opts.MinSpeech),
+		padding:    durationSamples(opts.Padding),
+		maxFrames:  durationFrames(opts.MaxChunk),
+		mergeGap:   durationFrames(opts.MergeGap),
+		mergeSpan:  durationFrames(opts.MergeTarget),
+	}
+}
+
+func (s *vadSegmenter) write(samples []float32) error {
+	s.buf = append(s.buf, samples...)
+
+	for {
+		off := s.frames*hopSize - s.bufStart
+		if off+hopSize > len(s.buf) {
+			break
+		}
+		for i := 0; i < hopSize; i++ {
+			v := s.buf[off+i]
+			if v > 1.0 {
+				v = 1.0
+			} else if v < -1.0 {
+				v = -1.0
+			}
+			s.frame[i] = int16(v * math.MaxInt16)
+		}
+
+		prob, err := s.vad.Process(s.frame)
+		if err != nil {
+			return fmt.Errorf("vad process frame %d: %w", s.frames, err)
+		}
+		s.step(prob)
+	}
+
+	if err := s.emitPending(false); err != nil {
+		return err
+	}
+	s.trim()
+	return nil
+}
+
+// step advances the segmenter by one frame with the VAD's speech
+// probability for it.
+func (s *vadSegmenter) step(prob float32) {
+	f := s.frames
+	s.frames++
+
+	isSpeech := prob >= s.threshold
+	if s.trace != nil {
+		s.trace.Frame(s.track, s.offset+float64(f*hopSize)/sampleRate, prob, isSpeech)
+	}
+	if isSpeech {
+		if !s.inSpeech {
+			s.speechStart = f
+			s.inSpeech = true
+			s.continued = false
+			s.probs = s.probs[:0]
+		}
+		s.silenceCount = 0
+	} else if s.inSpeech {
+		s.silenceCount++
+		if s.silenceCount >= s.silenceGap {
+			s.closeRegion(speechRegion{s.speechStart, f - s.silenceCount, !s.continued, true})
+			s.inSpeech = false
+			s.silenceCount = 0
+			return
+		}
+	}
+	if !s.inSpeech {
+		return
+	}
+	s.probs = append(s.probs, prob)
+	if s.maxFrames > 0 && len(s.probs) >= s.maxFrames {
+		s.split()
+	}
+}
+
+// splitSearch bounds how far back from the maximum chunk length split looks
+// for a pause.
+const splitSearch = 5 * time.Second
+
+// split cuts the open region, which has reached maxFrames, at the frame
+// with the lowest speech probability in the last splitSearch of it (or its
+// second half, for short limits), so the cut lands in a breath or a short
+// pause rather than mid-word. The part up to and including that frame is
+// closed; the rest stays open.
+func (s *vadSegmenter) split() {
+	window := durationFrames(splitSearch)
+	if window > s.maxFrames/2 {
+		window = s.maxFrames / 2
+	}
+	cut := len(s.probs) - 1
+	for i := len(s.probs) - 1; i >= len(s.probs)-window; i-- {
+		if s.probs[i] < s.probs[cut] {
+			cut = i
+		}
+	}
+	rest := s.probs[cut+1:]
+	if s.silenceCount > 0 && s.silenceCount >= len(rest) {
+		// The cut is in the trailing silence: close the region as if the
+		// silence gap had been reached, with padding
+		last := s.speechStart + len(s.probs) - 1 - s.silenceCount
+		s.closeRegion(speechRegion{s.speechStart, last, !s.continued, true})
+		s.inSpeech = false
+		s.silenceCount = 0
+		return
+	}
+	s.closeRegion(speechRegion{s.speechStart, s.speechStart + cut, !s.continued, false})
+	s.speechStart += cut + 1
+	s.continued = true
+	s.probs = s.probs[:copy(s.probs, rest)]
+}
+
+// flush closes a speech region still open at the end of the stream and
+// emits everything still pending.
+func (s *vadSegmenter) flush() error {
+	if s.inSpeech {
+		s.inSpeech = false
+		s.closeRegion(speechRegion{s.speechStart, s.frames - 1, !s.continued, true})
+	}
+	return s.emitPending(true)
+}
+
+// closeRegion queues r for emission, unless it holds less speech than
+// minSpeech, or merges it into the last queued region when they are close
+// enough. Pieces of a split region are always kept and never merged.
+func (s *vadSegmenter) closeRegion(r speechRegion) {
+	if r.padStart && r.padEnd && r.end-r.start+1 < s.minSpeech {
+		return
+	}
+	if n := len(s.pending); n > 0 && r.padStart && s.canMerge(s.pending[n-1], r.start, r.end) {
+		s.bridged = append(s.bridged, [2]int{s.pending[n-1].end + 1, r.start - 1})
+		s.pending[n-1].end = r.end
+		s.pending[n-1].padEnd = r.padEnd
+		return
+	}
+	s.pending = append(s.pending, r)
+}
+
+// canMerge reports whether a region spanning frames [start, end] may be
+// merged into r.
+func (s *vadSegmenter) canMerge(r speechRegion, start, end int) bool {
+	return s.mergeSpan > 0 && r.padEnd &&
+		start-r.end-1 <= s.mergeGap && end-r.start+1 <= s.mergeSpan
+}
+
+// awaitsMerge reports whether a region that has not closed yet could
+// still be merged into r: either the open one, or one starting with the
+// next frame.
+func (s *vadSegmenter) awaitsMerge(r speechRegion) bool {
+	if s.inSpeech {
+		return !s.continued && s.canMerge(r, s.speechStart, s.frames-1-s.silenceCount)
+	}
+	return s.canMerge(r, s.frames, s.frames)
+}
+
+// emitPending emits the queued regions whose trailing padding has been
+// read. A region closes silenceGap frames after its last speech frame, so
+// this is immediate unless the padding is longer than the silence gap, or
+// the last region may still absorb the next one. At the end of the stream
+// everything is emitted, clamped to what was read.
+func (s *vadSegmenter) emitPending(final bool) error {
+	for len(s.pending) > 0 {
+		_, endSamp := s.bounds(s.pending[0])
+		if !final && endSamp > s.bufStart+len(s.buf) {
+			break
+		}
+		if !final && len(s.pending) == 1 && s.awaitsMerge(s.pending[0]) {
+			break
+		}
+		if err := s.emitRegion(s.pending[0]); err != nil {
+			return err
+		}
+		s.pending = s.pending[1:]
+	}
+	return nil
+}
+
+// bounds returns the padded sample range [start, end) of r.
+func (s *vadSegmenter) bounds(r speechRegion) (int, int) {
+	start, end := r.start*hopSize, r.end*hopSize+hopSize
+	if r.padStart {
+		start -= s.padding
+	}
+	if r.padEnd {
+		end += s.padding
+	}
+	if start < 0 {
+		start = 0
+	}
+	return start, end
+}
+
+// emitRegion emits a copy of the padded samples of r as a chunk. The end is
+// clamped to the end of the stream.
+func (s *vadSegmenter) emitRegion(r speechRegion) error {
+	startSamp, endSamp := s.bounds(r)
+	total := s.bufStart + len(s.buf)
+	endSamp = min(endSamp, total)
+
+	// The speech of the regions merged into r, without the silence between
+	var speech [][2]float64
+	span := func(first, last int) {
+		from, to := first*hopSize, min(last*hopSize+hopSize, total)
+		speech = append(speech, [2]float64{
+			s.offset + float64(from)/sampleRate,
+			s.offset + float64(to)/sampleRate,
+		})
+	}
+	next := r.start
+	for len(s.bridged) > 0 && s.bridged[0][1] < r.end {
+		span(next, s.bridged[0][0]-1)
+		next = s.bridged[0][1] + 1
+		s.bridged = s.bridged[1:]
+	}
+	span(next, r.end)
+
+	samples := make([]float32, endSamp-startSamp)
+	copy(samples, s.buf[startSamp-s.bufStart:endSamp-s.bufStart])
+	startSec := s.offset + float64(startSamp)/sampleRate
+	if s.trace != nil {
+		s.trace.Chunk(s.track, startSec, s.offset+float64(endSamp)/sampleRate)
+	}
+	return s.emit(audioSegment{
+		samples:  samples,
+		startSec: startSec,
+		track:    s.track,
+		speech:   speech,
+	})
+}
+
+// trim drops samples that can no longer be part of a chunk: everything
+// before the oldest pending or open speech region, or before the padding
+// window of the next frame when there is none.
+func (s *vadSegmenter) trim() {
+	keep := s.frames*hopSize - s.padding
+	if len(s.pending) > 0 {
+		keep = s.pending[0].start*hopSize - s.padding
+	} else if s.inSpeech {
+		keep = s.speechStart*hopSize - s.padding
+	}
+	drop := keep - s.bufStart
+	// Compact only once half the buffer is stale, to keep copying amortized
+	if drop <= 0 || drop < len(s.buf)/2 {
+		return
+	}
+	n := copy(s.buf, s.buf[drop:])
+	s.buf = s.buf[:n]
+	s.bufStart += drop
+}
+
+// trimReader passes through the samples of r between from and to (sample
+// indices, to <= 0 meaning the end of the stream). Skipped audio is still
+// decoded, since inputs may not be seekable, but reading stops at to.
+type trimReader struct {
+	r      sampleReader
+	skip   int
+	remain int // samples left before to; negative when unbounded
+}
+
+func newTrimReader(r sampleReader, from, to int) *trimReader {
+	remain := -1
+	if to > 0 {
+		remain = to - from
+	}
+	return &trimReader{r: r, skip: from, remain: remain}
+}
+
+func (t *trimReader) Tracks() int { return t.r.Tracks() }
+
+func (t *trimReader) Read(bufs [][]float32) (int, error) {
+	for t.skip > 0 {
+		n, err := t.r.Read(bufs)
+		if n > t.skip {
+			// Keep the part of this block past the start of the range
+			for i := range bufs {
+				copy(bufs[i], bufs[i][t.skip:n])
+			}
+			n -= t.skip
+			t.skip = 0
+			return t.limit(n, err)
+		}
+		t.skip -= n
+		if err != nil {
+			return 0, err
+		}
+	}
+	if t.remain == 0 {
+		return 0, io.EOF
+	}
+	n, err := t.r.Read(bufs)
+	return t.limit(n, err)
+}
+
+func (t *trimReader) limit(n int, err error) (int, error) {
+	if t.remain < 0 {
+		return n, err
+	}
+	if n >= t.remain {
+		n = t.remain
+		err = io.EOF
+	}
+	t.remain -= n
+	return n, err
+}
+
+// sliceReader serves an in-memory signal as a single-track sampleReader.
+type sliceReader struct {
+	samples []float32
+}
+
+func (r *sliceReader) Tracks() int { return 1 }
+
+func (r *sliceReader) Read(bufs [][]float32) (int, error) {
+	if len(r.samples) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(bufs[0], r.samples)
+	r.samples = r.samples[n:]
+	return n, nil
+}
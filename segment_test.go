@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// syntheticWAV generates a 44.1 kHz stereo 16-bit WAV on the fly: 3s tone
+// bursts separated by 2s of silence. Nothing is buffered, so the reader
+// itself does not contribute to memory use however long the file is.
+type syntheticWAV struct {
+	header  []byte
+	frames  int // total frames
+	pos     int // frames emitted
+	onFrame func(pos int)
+}
+
+func newSyntheticWAV(seconds int) *syntheticWAV {
+	const rate, channels = 44100, 2
+	frames := seconds * rate
+	dataLen := frames * channels * 2
+
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], uint32(36+dataLen))
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], wavFormatPCM)
+	binary.LittleEndian.PutUint16(h[22:], channels)
+	binary.LittleEndian.PutUint32(h[24:], rate)
+	binary.LittleEndian.PutUint32(h[28:], rate*channels*2)
+	binary.LittleEndian.PutUint16(h[32:], channels*2)
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], uint32(dataLen))
+	return &syntheticWAV{header: h, frames: frames}
+}
+
+func (w *syntheticWAV) Read(p []byte) (int, error) {
+	if len(w.header) > 0 {
+		n := copy(p, w.header)
+		w.header = w.header[n:]
+		return n, nil
+	}
+	if w.pos >= w.frames {
+		return 0, io.EOF
+	}
+	n := 0
+	for ; n+4 <= len(p) && w.pos < w.frames; n += 4 {
+		var v int16
+		if t := float64(w.pos) / 44100; math.Mod(t, 5) < 3 {
+			v = int16(8000 * math.Sin(2*math.Pi*220*t))
+		}
+		binary.LittleEndian.PutUint16(p[n:], uint16(v))
+		binary.LittleEndian.PutUint16(p[n+2:], uint16(v))
+		w.pos++
+		if w.onFrame != nil {
+			w.onFrame(w.pos)
+		}
+	}
+	return n, nil
+}
+
+func TestVADOptions(t *testing.T) {
+	def := defaultVADOptions()
+	if err := def.validate(); err != nil {
+		t.Fatalf("default options invalid: %v", err)
+	}
+	// The defaults must keep the historical 19-frame gap and 200ms padding
+	if got := durationFrames(def.MinSilence); got != 19 {
+		t.Errorf("min silence = %d frames, want 19", got)
+	}
+	if got := durationSamples(def.Padding); got != 3200 {
+		t.Errorf("padding = %d samples, want 3200", got)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*VADOptions)
+	}{
+		{"zero threshold", func(o *VADOptions) { o.Threshold = 0 }},
+		{"threshold of one", func(o *VADOptions) { o.Threshold = 1 }},
+		{"min silence below one frame", func(o *VADOptions) { o.MinSilence = time.Millisecond }},
+		{"negative padding", func(o *VADOptions) { o.Padding = -time.Millisecond }},
+		{"negative min speech", func(o *VADOptions) { o.MinSpeech = -time.Second }},
+		{"unknown backend", func(o *VADOptions) { o.Backend = "webrtc" }},
+		{"negative merge gap", func(o *VADOptions) { o.MergeGap = -time.Second }},
+		{"merge target above max chunk", func(o *VADOptions) { o.MaxChunk, o.MergeTarget = 20*time.Second, 30*time.Second }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := defaultVADOptions()
+			tt.modify(&o)
+			if err := o.validate(); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+// runSegmenter drives a vadSegmenter with a fixed probability per frame,
+// bypassing the VAD, and returns the chunks as [start, end) sample ranges.
+func runSegmenter(t *testing.T, opts VADOptions, probs []float32) [][2]int {
+	t.Helper()
+	var got [][2]int
+	for _, seg := range segmentProbs(t, opts, probs) {
+		start := int(math.Round(seg.startSec * sampleRate))
+		got = append(got, [2]int{start, start + len(seg.samples)})
+	}
+	return got
+}
+
+// segmentProbs is runSegmenter returning the emitted chunks themselves.
+func segmentProbs(t *testing.T, opts VADOptions, probs []float32) []audioSegment {
+	t.Helper()
+	var got []audioSegment
+	s := newVADSegmenter(nil, opts, 0, 0, func(seg audioSegment) error {
+		got = append(got, seg)
+		return nil
+	})
+	frame := make([]float32, hopSize)
+	for _, p := range probs {
+		s.buf = append(s.buf, frame...)
+		s.step(p)
+		if err := s.emitPending(false); err != nil {
+			t.Fatal(err)
+		}
+		s.trim()
+	}
+	if err := s.flush(); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// frameProbs builds a probability sequence from runs of (count, prob).
+func frameProbs(runs ...float32) []float32 {
+	var probs []float32
+	for i := 0; i+1 < len(runs); i += 2 {
+		for n := 0; n < int(runs[i]); n++ {
+			probs = append(probs, runs[i+1])
+		}
+	}
+	return probs
+}
+
+func TestSegmenterMaxChunk(t *testing.T) {
+	const pad = 3200 // 200ms
+	frames := func(n int) time.Duration { return time.Duration(n*hopSize) * time.Second / sampleRate }
+
+	tests := []struct {
+		name     string
+		maxChunk time.Duration
+		probs    []float32
+		want     [][2]int
+	}{
+		{
+			name:  "no limit",
+			probs: frameProbs(20, 0.1, 100, 0.9, 30, 0.1),
+			want:  [][2]int{{20*hopSize - pad, 120*hopSize + pad}},
+		},
+		{
+			name:     "under the limit",
+			maxChunk: frames(120),
+			probs:    frameProbs(20, 0.1, 100, 0.9, 30, 0.1),
+			want:     [][2]int{{20*hopSize - pad, 120*hopSize + pad}},
+		},
+		{
+			// The dip at frame 90 is inside the search window (the last 40
+			// of 80 frames), so the cut lands there, without padding
+			name:     "split at the quietest frame",
+			maxChunk: frames(80),
+			probs:    frameProbs(20, 0.1, 70, 0.9, 1, 0.6, 29, 0.9, 30, 0.1),
+			want:     [][2]int{{20*hopSize - pad, 91 * hopSize}, {91 * hopSize, 120*hopSize + pad}},
+		},
+		{
+			// A dip before the window is ignored: the cut goes to the
+			// lowest frame inside it, the latest one on ties
+			name:     "dip outside the window",
+			maxChunk: frames(80),
+			probs:    frameProbs(20, 0.1, 10, 0.9, 1, 0.55, 89, 0.9, 30, 0.1),
+			want:     [][2]int{{20*hopSize - pad, 100 * hopSize}, {100 * hopSize, 120*hopSize + pad}},
+		},
+		{
+			name:     "repeated splits",
+			maxChunk: frames(40),
+			probs:    frameProbs(100, 0.9),
+			want:     [][2]int{{0, 40 * hopSize}, {40 * hopSize, 80 * hopSize}, {80 * hopSize, 100 * hopSize}},
+		},
+		{
+			// The limit is reached in the trailing silence: the region
+			// closes normally, padding included
+			name:     "limit reached in silence",
+			maxChunk: frames(80),
+			probs:    frameProbs(20, 0.1, 75, 0.9, 40, 0.1),
+			want:     [][2]int{{20*hopSize - pad, 95*hopSize + pad}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultVADOptions()
+			opts.MaxChunk = tt.maxChunk
+			got := runSegmenter(t, opts, tt.probs)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("chunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmenterMerge(t *testing.T) {
+	const pad = 3200 // 200ms
+
+	tests := []struct {
+		name   string
+		target time.Duration
+		gap    time.Duration
+		probs  []float32
+		want   [][2]int
+	}{
+		{
+			name:  "disabled",
+			gap:   time.Second,
+			probs: frameProbs(20, 0.1, 30, 0.9, 25, 0.1, 30, 0.9, 40, 0.1),
+			want:  [][2]int{{20*hopSize - pad, 50*hopSize + pad}, {75*hopSize - pad, 105*hopSize + pad}},
+		},
+		{
+			name:   "short gap",
+			target: 10 * time.Second,
+			gap:    time.Second,
+			probs:  frameProbs(20, 0.1, 30, 0.9, 25, 0.1, 30, 0.9, 40, 0.1),
+			want:   [][2]int{{20*hopSize - pad, 105*hopSize + pad}},
+		},
+		{
+			name:   "gap too long",
+			target: 10 * time.Second,
+			gap:    time.Second,
+			probs:  frameProbs(20, 0.1, 30, 0.9, 70, 0.1, 30, 0.9, 40, 0.1),
+			want:   [][2]int{{20*hopSize - pad, 50*hopSize + pad}, {120*hopSize - pad, 150*hopSize + pad}},
+		},
+		{
+			// 1.5s is 94 frames: the first two regions span 85, adding the
+			// third would make 140
+			name:   "target reached",
+			target: 1500 * time.Millisecond,
+			gap:    time.Second,
+			probs:  frameProbs(20, 0.1, 30, 0.9, 25, 0.1, 30, 0.9, 25, 0.1, 30, 0.9, 40, 0.1),
+			want:   [][2]int{{20*hopSize - pad, 105*hopSize + pad}, {130*hopSize - pad, 160*hopSize + pad}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := defaultVADOptions()
+			opts.MergeGap = tt.gap
+			opts.MergeTarget = tt.target
+			got := runSegmenter(t, opts, tt.probs)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("chunks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentByVADEnergy(t *testing.T) {
+	// Speech-like bursts: 1-2s and 3-4.5s of a 5.5s signal
+	bursts := [][2]float64{{1, 2}, {3, 4.5}}
+	samples := make([]float32, int(5.5*sampleRate))
+	for i := range samples {
+		for _, b := range bursts {
+			if sec := float64(i) / sampleRate; sec >= b[0] && sec < b[1] {
+				samples[i] = float32(tone(220, 0.3)(i))
+			}
+		}
+	}
+
+	opts := defaultVADOptions()
+	opts.Backend = "energy"
+	chunks, err := segmentByVAD(samples, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != len(bursts) {
+		t.Fatalf("got %d chunks, want %d", len(chunks), len(bursts))
+	}
+	const frame = float64(hopSize) / sampleRate
+	for i, c := range chunks {
+		// Each chunk is its burst plus 200ms padding, within a frame or two
+		wantStart := bursts[i][0] - 0.2
+		wantEnd := bursts[i][1] + 0.2
+		end := c.startSec + float64(len(c.samples))/sampleRate
+		if math.Abs(c.startSec-wantStart) > 2*frame || math.Abs(end-wantEnd) > 2*frame {
+			t.Errorf("chunk %d = [%.3f, %.3f], want [%.3f, %.3f]", i, c.startSec, end, wantStart, wantEnd)
+		}
+	}
+}
+
+func TestTrimReader(t *testing.T) {
+	signal := make([]float32, 100)
+	for i := range signal {
+		signal[i] = float32(i)
+	}
+
+	tests := []struct {
+		name      string
+		from, to  int
+		wantFirst float32
+		wantLen   int
+	}{
+		{"no trim", 0, 0, 0, 100},
+		{"from only", 30, 0, 30, 70},
+		{"to only", 0, 45, 0, 45},
+		{"both, across block boundaries", 13, 77, 13, 64},
+		{"past the end", 120, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTrimReader(&sliceReader{samples: signal}, tt.from, tt.to)
+			var got []float32
+			// Blocks of 10 make the skip land mid-block
+			bufs := [][]float32{make([]float32, 10)}
+			for {
+				n, err := r.Read(bufs)
+				got = append(got, bufs[0][:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(got) != tt.wantLen {
+				t.Fatalf("got %d samples, want %d", len(got), tt.wantLen)
+			}
+			for i, v := range got {
+				if v != tt.wantFirst+float32(i) {
+					t.Fatalf("sample %d = %v, want %v", i, v, tt.wantFirst+float32(i))
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkStreamingMemory decodes, resamples and segments synthetic files
+// of increasing length. peak-heap-MB should stay flat across lengths, since
+// only the open speech region is buffered.
+//
+//	go test -run '^$' -bench StreamingMemory -benchtime 1x
+func BenchmarkStreamingMemory(b *testing.B) {
+	// The energy backend needs no native library, so this runs with notenvad
+	opts := defaultVADOptions()
+	opts.Backend = "energy"
+	for _, minutes := range []int{1, 10, 60} {
+		b.Run(fmt.Sprintf("%dmin", minutes), func(b *testing.B) {
+			var peak uint64
+			var ms runtime.MemStats
+			sample := func(pos int) {
+				// Sampling every 10s of audio is enough to see growth
+				if pos%441000 == 0 {
+					runtime.ReadMemStats(&ms)
+					if ms.HeapInuse > peak {
+						peak = ms.HeapInuse
+					}
+				}
+			}
+
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				runtime.GC()
+				src := newSyntheticWAV(minutes * 60)
+				src.onFrame = sample
+				d, err := newAudioDecoder(src)
+				if err != nil {
+					b.Fatal(err)
+				}
+				chunks := 0
+				pcm, err := newPCMReader(d, channelMix)
+				if err != nil {
+					b.Fatal(err)
+				}
+				err = segmentStream(pcm, 0, opts, func(audioSegment) error {
+					chunks++
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// stopwordLists are the built-in stopwords by language, words too common
+// to show that a segment says anything. Whisper's language codes.
+var stopwordLists = map[string]string{
+	"en": `the and for you this that with from have are was were been but not all
+		any can will would could should what when where which who why how there their
+		they them then than these those its our your his her him she just also very
+		about into over again more some such only too out`,
+	"uk": `але або адже біля був була були було бути вам вас ваш вже від вони воно
+		все всі вона для його її їх коли лише мене мені мій між нам нас наш них ним
+		ніж про при так там тим тих тобі того той треба тут хоча цей чи що щоб як
+		яка який які якщо`,
+	"ru": `без был была были было быть вам вас ваш вот все всё где для его еще ещё
+		или как когда кто мне мой нас нет них она они оно под при про раз так там
+		тебя тем тоже только тут уже хотя чем что чтобы эта эти это этот`,
+	"de": `aber alle als also auch auf aus bei bin bis das dass dem den der des die
+		dir doch ein eine einem einen einer für hat hatte ich ihr ist mit nach nicht
+		noch nur oder sich sie sind über und uns von war was wie wir wird zum zur`,
+	"fr": `alors aux avec ces cette dans des elle elles est eux ils les leur lui mais
+		mes moi mon nos notre nous par pas pour que qui ses son sur toi ton une vos
+		votre vous était être avoir`,
+	"es": `algo como con del desde donde ella ellos entre era esa ese eso esta este
+		esto está fue las les los más muy nos para pero por que qué sin son sus
+		también una uno usted`,
+}
+
+// stopwordSets holds stopwords by language code.
+type stopwordSets map[string]map[string]struct{}
+
+var builtinStopwords = newBuiltinStopwords()
+
+func newBuiltinStopwords() stopwordSets {
+	sets := stopwordSets{}
+	for lang, words := range stopwordLists {
+		sets.add(lang, strings.Fields(words))
+	}
+	return sets
+}
+
+func (s stopwordSets) add(lang string, words []string) {
+	set, ok := s[lang]
+	if !ok {
+		set = map[string]struct{}{}
+		s[lang] = set
+	}
+	for _, w := range words {
+		set[normalizeWord(w)] = struct{}{}
+	}
+}
+
+// load adds the stopwords of a file, listed under a header naming their
+// language and separated by spaces or newlines:
+//
+//	# Polish
+//	[pl]
+//	jest nie się
+func (s stopwordSets) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var lang string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			lang = strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			if lang == "" {
+				return fmt.Errorf("%s:%d: empty language", path, n)
+			}
+			continue
+		}
+		if lang == "" {
+			return fmt.Errorf("%s:%d: words before the first [language] header", path, n)
+		}
+		s.add(lang, strings.Fields(line))
+	}
+	return scanner.Err()
+}
+
+// forLang returns the stopwords of lang. Languages without a list, and
+// text of unknown language, get the English list.
+func (s stopwordSets) forLang(lang string) map[string]struct{} {
+	if set, ok := s[lang]; ok {
+		return set
+	}
+	return s["en"]
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+func TestCountRealWords(t *testing.T) {
+	tests := []struct {
+		lang, text string
+		want       int
+	}{
+		{"en", "And then, for this?", 0},
+		{"en", "The budget was approved.", 2},
+		{"uk", "Але що це було?", 0},
+		{"uk", "Бюджет затвердили вчора.", 3},
+		{"ru", "Что это было, когда?", 0},
+		{"ru", "Бюджет утвердили вчера.", 3},
+		{"de", "Aber das ist nicht so.", 0},
+		{"de", "Der Haushalt wurde genehmigt.", 3},
+		{"fr", "Mais pour nous, avec elle.", 0},
+		{"fr", "Le budget est approuvé.", 2},
+		{"es", "Pero para que, con ella.", 0},
+		{"es", "El presupuesto fue aprobado.", 2},
+		{"", "And then, for this?", 0},        // unknown language: English
+		{"pl", "The budget was approved.", 2}, // no list: English
+		{"uk", "the and for", 3},              // English stopwords are words in Ukrainian text
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.text, func(t *testing.T) {
+			if got := countRealWords(tt.text, builtinStopwords.forLang(tt.lang)); got != tt.want {
+				t.Errorf("countRealWords(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStopwordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopwords.txt")
+	content := "# Polish\n[pl]\njest nie się\ntego\n\n[EN]\nwell\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := defaultFilterConfig()
+	cfg.Stopwords = []string{path}
+	if err := cfg.loadLists(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		lang, text string
+		want       string // rule
+	}{
+		{"pl", "Tego nie jest.", ruleRealWords},
+		{"pl", "Budżet jest zatwierdzony.", ""},
+		{"en", "Well, well.", ruleRealWords},
+		{"en", "And then, for this?", ruleRealWords}, // built-in list kept
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.text, func(t *testing.T) {
+			segment := whisper.Segment{Text: tt.text, Tokens: []whisper.Token{{P: 0.9}}}
+			if got := filterSegment(segment, cfg, tt.lang).Rule; got != tt.want {
+				t.Errorf("filterSegment() rule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if _, found := builtinStopwords["pl"]; found {
+		t.Error("loading changed the built-in stopwords")
+	}
+
+	if err := (stopwordSets{}).load(writeList(t, "jest nie\n")); err == nil {
+		t.Error("expected an error for words before a language header")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// vadTimeline is a vadTracer that writes the VAD probability timeline, for
+// plotting segmentation against the audio. Frames are written as they come,
+// so memory stays flat however long the input is.
+//
+// CSV has one row per frame and one per chunk, told apart by the kind
+// column:
+//
+//	kind,track,start,end,prob,speech
+//	frame,0,1.024,1.040,0.8731,1
+//	chunk,0,0.824,3.312,,
+//
+// JSON has the same data as {"frames": [...], "chunks": [...]}.
+type vadTimeline struct {
+	w      *bufio.Writer
+	json   bool
+	frames int
+	chunks []timelineChunk // JSON only: written after the frames
+}
+
+type timelineFrame struct {
+	Track  int     `json:"track"`
+	Time   float64 `json:"time"`
+	Prob   float32 `json:"prob"`
+	Speech bool    `json:"speech"`
+}
+
+type timelineChunk struct {
+	Track int     `json:"track"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// timelineFormat picks the timeline format from a file name: json for
+// .json, csv otherwise.
+func timelineFormat(path string) string {
+	if strings.HasSuffix(strings.ToLower(path), ".json") {
+		return "json"
+	}
+	return "csv"
+}
+
+func newVADTimeline(w io.Writer, format string) *vadTimeline {
+	t := &vadTimeline{w: bufio.NewWriter(w), json: format == "json"}
+	if t.json {
+		t.w.WriteString("{\n  \"frames\": [")
+	} else {
+		t.w.WriteString("kind,track,start,end,prob,speech\n")
+	}
+	return t
+}
+
+func (t *vadTimeline) Frame(track int, time float64, prob float32, speech bool) {
+	if t.json {
+		sep := ","
+		if t.frames == 0 {
+			sep = ""
+		}
+		b, _ := json.Marshal(timelineFrame{track, roundMillis(time), prob, speech})
+		fmt.Fprintf(t.w, "%s\n    %s", sep, b)
+	} else {
+		flag := 0
+		if speech {
+			flag = 1
+		}
+		fmt.Fprintf(t.w, "frame,%d,%.3f,%.3f,%.4f,%d\n", track, time, time+float64(hopSize)/sampleRate, prob, flag)
+	}
+	t.frames++
+}
+
+func (t *vadTimeline) Chunk(track int, start, end float64) {
+	if t.json {
+		t.chunks = append(t.chunks, timelineChunk{track, roundMillis(start), roundMillis(end)})
+	} else {
+		fmt.Fprintf(t.w, "chunk,%d,%.3f,%.3f,,\n", track, start, end)
+	}
+}
+
+// Close finishes the document and flushes it; it does not close the
+// underlying writer.
+func (t *vadTimeline) Close() error {
+	if t.json {
+		t.w.WriteString("\n  ],\n  \"chunks\": ")
+		chunks := t.chunks
+		if chunks == nil {
+			chunks = []timelineChunk{}
+		}
+		b, err := json.MarshalIndent(chunks, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		t.w.Write(b)
+		t.w.WriteString("\n}\n")
+	}
+	return t.w.Flush()
+}
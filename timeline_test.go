@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestVADTimeline(t *testing.T) {
+	probs := frameProbs(5, 0.1, 30, 0.9, 25, 0.1)
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		timeline := newVADTimeline(&buf, timelineFormat("timeline.csv"))
+		opts := defaultVADOptions()
+		opts.Trace = timeline
+		runSegmenter(t, opts, probs)
+		if err := timeline.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if want := 1 + len(probs) + 1; len(lines) != want {
+			t.Fatalf("got %d lines, want %d:\n%s", len(lines), want, buf.String())
+		}
+		for i, want := range map[int]string{
+			0: "kind,track,start,end,prob,speech",
+			1: "frame,0,0.000,0.016,0.1000,0",
+			6: "frame,0,0.080,0.096,0.9000,1",
+		} {
+			if lines[i] != want {
+				t.Errorf("line %d = %q, want %q", i, lines[i], want)
+			}
+		}
+		// The chunk is written when it closes: 5 frames in, padded by 200ms
+		// (clamped at the start) and closed 19 silent frames after speech
+		if want := "chunk,0,0.000,0.760,,"; !strings.Contains(buf.String(), want+"\n") {
+			t.Errorf("missing %q:\n%s", want, buf.String())
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		timeline := newVADTimeline(&buf, timelineFormat("timeline.JSON"))
+		opts := defaultVADOptions()
+		opts.Trace = timeline
+		runSegmenter(t, opts, probs)
+		if err := timeline.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var doc struct {
+			Frames []timelineFrame `json:"frames"`
+			Chunks []timelineChunk `json:"chunks"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+		}
+		if len(doc.Frames) != len(probs) {
+			t.Errorf("got %d frames, want %d", len(doc.Frames), len(probs))
+		}
+		if f := doc.Frames[5]; f.Time != 0.08 || !f.Speech || f.Prob != 0.9 {
+			t.Errorf("frame 5 = %+v", f)
+		}
+		if len(doc.Chunks) != 1 || doc.Chunks[0] != (timelineChunk{0, 0, 0.76}) {
+			t.Errorf("chunks = %+v", doc.Chunks)
+		}
+	})
+}
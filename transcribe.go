@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// transcribeOptions are the whisper settings applied to every chunk.
+type transcribeOptions struct {
+	Lang      string
+	Translate bool
+	Prompt    string
+	Threads   int // per worker
+	Decoding  decodingOptions
+	Words     bool // word-level timestamps
+	Filter    filterConfig
+
+	// CarryTokens is how many text tokens of a track's previous chunk are
+	// added to the prompt of its next chunk; 0 transcribes every chunk cold.
+	CarryTokens int
+}
+
+// maxCarryTokens is the most whisper takes from a prompt: half its 448-token
+// text context.
+const maxCarryTokens = 224
+
+// stateModel is implemented by models of the patched binding (see
+// patches/whisper-bindings-state.patch). Upstream, every context decodes
+// into its model's single whisper state, so contexts of one model cannot
+// run concurrently; NewStateContext gives each context a state of its own.
+type stateModel interface {
+	NewStateContext() (whisper.Context, error)
+}
+
+// newChunkContext creates a context of model configured with opts. A
+// context holds the decoding parameters and, with the patched binding, its
+// own decoding state, so one is built per worker and reused for every
+// chunk. Release it with closeContext.
+func newChunkContext(model whisper.Model, opts transcribeOptions) (whisper.Context, error) {
+	newContext := model.NewContext
+	if m, ok := model.(stateModel); ok {
+		newContext = m.NewStateContext
+	}
+	ctx, err := newContext()
+	if err != nil {
+		return nil, fmt.Errorf("create context: %w", err)
+	}
+	if err := ctx.SetLanguage(opts.Lang); err != nil {
+		return nil, fmt.Errorf("set language %q: %w", opts.Lang, err)
+	}
+	ctx.SetThreads(uint(opts.Threads))
+	ctx.SetTranslate(opts.Translate)
+	if err := opts.Decoding.apply(ctx); err != nil {
+		return nil, err
+	}
+	if opts.Prompt != "" {
+		ctx.SetInitialPrompt(opts.Prompt)
+	}
+	ctx.SetTokenTimestamps(opts.Words)
+	return ctx, nil
+}
+
+// closeContext frees the decoding state of a context from newChunkContext,
+// if it has one of its own.
+func closeContext(ctx whisper.Context) {
+	if c, ok := ctx.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// droppedSegment is a segment the hallucination filter dropped, with the
+// verdict, for -filter-report.
+type droppedSegment struct {
+	transcriptSegment
+	Verdict filterVerdict `json:"filter"`
+}
+
+// chunkResult is what one chunk produced.
+type chunkResult struct {
+	segments []transcriptSegment // passed the hallucination filter, timestamps absolute
+	dropped  []droppedSegment    // with the word loops cut from kept segments
+	tokens   []string            // text tokens of segments
+}
+
+// transcribeChunk runs whisper on one chunk, cuts word loops, and sorts its
+// segments by the verdict of the hallucination filter configured in opts.
+// Loops cut from kept segments are reported with the dropped segments.
+// With opts.Words, the kept segments carry their words; ctx must have been
+// created with the same opts.
+func transcribeChunk(ctx whisper.Context, opts transcribeOptions, chunk audioSegment, speaker string) (chunkResult, error) {
+	var res chunkResult
+	offset := time.Duration(chunk.startSec * float64(time.Second))
+	segmentCb := func(segment whisper.Segment) {
+		// Scores describe what whisper produced, loops included
+		seg := transcriptSegment{
+			Start:   formatDuration(segment.Start + offset),
+			End:     formatDuration(segment.End + offset),
+			Speaker: speaker,
+
+			segmentScores: scoreSegment(segment, ctx.IsText, opts.Filter),
+		}
+		original := segment.Text
+		var keep []bool // words left by truncateLoops
+		if opts.Filter.enabled(ruleWordLoop) {
+			segment.Text, keep = truncateLoops(segment.Text)
+		}
+		seg.Text = segment.Text
+		if verdict := filterSegment(segment, opts.Filter, textLanguage(ctx, opts)); verdict.Dropped() {
+			res.dropped = append(res.dropped, droppedSegment{seg, verdict})
+			return
+		}
+		if keep != nil {
+			res.dropped = append(res.dropped, wordLoopReport(seg, original)...)
+		}
+		if opts.Words {
+			seg.Words = maskWords(segmentWords(segment, ctx.IsText, offset), keep)
+		}
+		res.segments = append(res.segments, seg)
+		if keep != nil {
+			return // a loop in the prompt invites another
+		}
+		for _, tok := range segment.Tokens {
+			if ctx.IsText(tok) {
+				res.tokens = append(res.tokens, tok.Text)
+			}
+		}
+	}
+	if err := ctx.Process(chunk.samples, nil, segmentCb, nil); err != nil {
+		return chunkResult{}, err
+	}
+	return res, nil
+}
+
+// textLanguage returns the language of the text ctx produces: English when
+// translating, otherwise the requested or, with auto, detected language.
+func textLanguage(ctx whisper.Context, opts transcribeOptions) string {
+	switch {
+	case opts.Translate:
+		return "en"
+	case opts.Lang == "" || opts.Lang == "auto":
+		return ctx.DetectedLanguage()
+	}
+	return opts.Lang
+}
+
+// promptCarry builds each chunk's initial prompt from the user's prompt and
+// the tail of the text accepted on the same track so far, so names and
+// terms whisper got right once are spelled the same way in later chunks.
+type promptCarry struct {
+	prompt string
+	budget int              // text tokens carried
+	tail   map[int][]string // per track, oldest first
+}
+
+func newPromptCarry(prompt string, budget int) *promptCarry {
+	return &promptCarry{prompt: prompt, budget: budget, tail: map[int][]string{}}
+}
+
+// Prompt returns the initial prompt for the next chunk of track.
+func (c *promptCarry) Prompt(track int) string {
+	return strings.TrimSpace(c.prompt + strings.Join(c.tail[track], ""))
+}
+
+// Add records the text tokens accepted from a chunk of track. A chunk with
+// no accepted text keeps the previous context.
+func (c *promptCarry) Add(track int, tokens []string) {
+	tail := append(c.tail[track], tokens...)
+	if len(tail) > c.budget {
+		tail = tail[len(tail)-c.budget:]
+		// Start on a word: tokens that begin a word carry its leading space
+		for len(tail) > 1 && !strings.HasPrefix(tail[0], " ") {
+			tail = tail[1:]
+		}
+	}
+	c.tail[track] = tail
+}
+
+// chunkPool transcribes chunks on a number of worker goroutines, each
+// reusing a single context. All contexts come from one loaded model and
+// each decodes into a whisper state of its own (see stateModel), so the
+// weights are shared and only the decoding state is per worker. Carrying
+// the prompt from chunk to chunk needs them in order, so it is limited to a
+// single worker.
+//
+// Results are kept by submission order, so Wait returns exactly what a
+// sequential run would have produced.
+type chunkPool struct {
+	opts   transcribeOptions
+	labels []string     // speaker label per track
+	carry  *promptCarry // nil unless CarryTokens is set
+
+	jobs chan chunkJob
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	results []chunkResult // by submission order
+	err     error         // first error, reported by Submit and Wait
+}
+
+type chunkJob struct {
+	seq   int
+	chunk audioSegment
+}
+
+func newChunkPool(model whisper.Model, workers int, opts transcribeOptions, labels []string) (*chunkPool, error) {
+	if opts.CarryTokens > 0 && workers > 1 {
+		return nil, fmt.Errorf("carrying the prompt between chunks needs a single worker, got %d", workers)
+	}
+	if _, ok := model.(stateModel); !ok && workers > 1 {
+		return nil, fmt.Errorf("parallel workers need the patched whisper bindings; run make patch")
+	}
+	contexts := make([]whisper.Context, workers)
+	for i := range contexts {
+		ctx, err := newChunkContext(model, opts)
+		if err != nil {
+			for _, c := range contexts[:i] {
+				closeContext(c)
+			}
+			return nil, err
+		}
+		contexts[i] = ctx
+	}
+
+	p := &chunkPool{
+		opts:   opts,
+		labels: labels,
+		// One queued chunk per worker keeps them busy while VAD finds the
+		// next one, without buffering more audio than needed
+		jobs: make(chan chunkJob, workers),
+	}
+	if opts.CarryTokens > 0 {
+		p.carry = newPromptCarry(opts.Prompt, opts.CarryTokens)
+	}
+	for _, ctx := range contexts {
+		p.wg.Add(1)
+		go p.work(ctx)
+	}
+	return p, nil
+}
+
+func (p *chunkPool) work(ctx whisper.Context) {
+	defer p.wg.Done()
+	defer closeContext(ctx)
+	for job := range p.jobs {
+		if p.failed() {
+			continue // drain, so Submit never blocks after a failure
+		}
+		track := job.chunk.track
+		if p.carry != nil {
+			ctx.SetInitialPrompt(p.carry.Prompt(track))
+		}
+		res, err := transcribeChunk(ctx, p.opts, job.chunk, p.labels[track])
+		if p.carry != nil {
+			p.carry.Add(track, res.tokens)
+		}
+		p.mu.Lock()
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("chunk %d: %w", job.seq+1, err)
+		}
+		p.results[job.seq] = res
+		p.mu.Unlock()
+	}
+}
+
+func (p *chunkPool) failed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err != nil
+}
+
+// Submit queues a chunk, blocking while all workers are busy. It returns
+// the first error any worker ran into, so that segmentation stops early.
+func (p *chunkPool) Submit(chunk audioSegment) error {
+	p.mu.Lock()
+	err := p.err
+	seq := len(p.results)
+	p.results = append(p.results, chunkResult{})
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	p.jobs <- chunkJob{seq: seq, chunk: chunk}
+	return nil
+}
+
+// Wait finishes the queued chunks and returns the segments of all chunks in
+// submission order.
+func (p *chunkPool) Wait() ([]transcriptSegment, error) {
+	close(p.jobs)
+	p.wg.Wait()
+	if p.err != nil {
+		return nil, p.err
+	}
+	var segments []transcriptSegment
+	for _, r := range p.results {
+		segments = append(segments, r.segments...)
+	}
+	return segments, nil
+}
+
+// Dropped returns the segments the hallucination filter dropped, in
+// submission order. It is only valid after Wait.
+func (p *chunkPool) Dropped() []droppedSegment {
+	var dropped []droppedSegment
+	for _, r := range p.results {
+		dropped = append(dropped, r.dropped...)
+	}
+	return dropped
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// fakeModel stands in for a whisper model: each chunk yields two segments
+// naming the chunk, after a random delay so parallel workers finish out of
+// order. Embedding the interfaces provides the methods the pool never calls.
+type fakeModel struct {
+	whisper.Model
+	mu     sync.Mutex
+	rng    *rand.Rand
+	failAt float64 // chunk start that makes Process fail; negative for none
+
+	contexts int      // created so far
+	closed   int      // contexts whose state was freed
+	prompts  []string // every SetInitialPrompt, in order
+}
+
+func (m *fakeModel) NewContext() (whisper.Context, error) {
+	m.mu.Lock()
+	m.contexts++
+	m.mu.Unlock()
+	return &fakeContext{model: m}, nil
+}
+
+// NewStateContext makes fakeModel a stateModel, as with the patched binding.
+func (m *fakeModel) NewStateContext() (whisper.Context, error) {
+	return m.NewContext()
+}
+
+type fakeContext struct {
+	whisper.Context
+	model *fakeModel
+}
+
+func (c *fakeContext) SetLanguage(string) error       { return nil }
+func (c *fakeContext) SetThreads(uint)                {}
+func (c *fakeContext) SetTranslate(bool)              {}
+func (c *fakeContext) SetBeamSize(int)                {}
+func (c *fakeContext) SetTemperature(float32)         {}
+func (c *fakeContext) SetTemperatureFallback(float32) {}
+func (c *fakeContext) IsText(t whisper.Token) bool    { return t.Id < 50257 }
+
+func (c *fakeContext) SetTokenTimestamps(bool) {}
+
+func (c *fakeContext) DetectedLanguage() string { return "en" }
+
+func (c *fakeContext) SetInitialPrompt(prompt string) {
+	c.model.mu.Lock()
+	c.model.prompts = append(c.model.prompts, prompt)
+	c.model.mu.Unlock()
+}
+
+func (c *fakeContext) Close() error {
+	c.model.mu.Lock()
+	c.model.closed++
+	c.model.mu.Unlock()
+	return nil
+}
+
+func (c *fakeContext) Process(samples []float32, _ whisper.EncoderBeginCallback, cb whisper.SegmentCallback, _ whisper.ProgressCallback) error {
+	c.model.mu.Lock()
+	delay := time.Duration(c.model.rng.Intn(3)) * time.Millisecond
+	c.model.mu.Unlock()
+	time.Sleep(delay)
+
+	start := float64(samples[0])
+	if start == c.model.failAt {
+		return errors.New("decoder failed")
+	}
+	for i := 0; i < 2; i++ {
+		text := fmt.Sprintf("Chunk starting at %v, part %d of the speech.", start, i)
+		// One token per word, after a special timestamp token
+		tokens := []whisper.Token{{Id: 50365, Text: "[_TT_0]"}}
+		for _, w := range strings.Fields(text) {
+			tokens = append(tokens, whisper.Token{Id: len(tokens), Text: " " + w})
+		}
+		cb(whisper.Segment{
+			Num:    i,
+			Start:  time.Duration(i) * time.Second,
+			End:    time.Duration(i+1) * time.Second,
+			Text:   text,
+			Tokens: tokens,
+		})
+	}
+	return nil
+}
+
+// runPool transcribes chunks starting at the given seconds, each tagged
+// with its start in the first sample, on a pool of the given size.
+func runPool(t *testing.T, workers int, starts []float64, failAt float64) ([]transcriptSegment, error) {
+	t.Helper()
+	model := &fakeModel{rng: rand.New(rand.NewSource(int64(workers))), failAt: failAt}
+	// Contexts are created once per worker, not once per chunk, all from
+	// the one model, and freed when the pool finishes
+	defer func() {
+		if model.contexts != workers || model.closed != workers {
+			t.Errorf("created %d and closed %d contexts for %d workers", model.contexts, model.closed, workers)
+		}
+	}()
+	pool, err := newChunkPool(model, workers, transcribeOptions{Lang: "en", Threads: 1, Filter: defaultFilterConfig()}, []string{""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range starts {
+		if err := pool.Submit(audioSegment{samples: []float32{float32(s)}, startSec: s}); err != nil {
+			pool.Wait()
+			return nil, err
+		}
+	}
+	return pool.Wait()
+}
+
+func TestChunkPoolOrder(t *testing.T) {
+	starts := make([]float64, 40)
+	for i := range starts {
+		starts[i] = float64(i * 3)
+	}
+	want, err := runPool(t, 1, starts, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) != 2*len(starts) {
+		t.Fatalf("sequential run kept %d segments, want %d", len(want), 2*len(starts))
+	}
+	for _, workers := range []int{2, 4, 8} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			got, err := runPool(t, workers, starts, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("parallel output differs from sequential:\n got %v\nwant %v", got, want)
+			}
+		})
+	}
+}
+
+func TestChunkPoolError(t *testing.T) {
+	starts := make([]float64, 40)
+	for i := range starts {
+		starts[i] = float64(i)
+	}
+	_, err := runPool(t, 4, starts, 5)
+	if err == nil || !strings.Contains(err.Error(), "chunk 6: decoder failed") {
+		t.Errorf("err = %v, want chunk 6 failure", err)
+	}
+}
+
+func TestChunkPoolUnpatchedBinding(t *testing.T) {
+	// Hides NewStateContext: contexts share the model's decoding state
+	model := struct{ whisper.Model }{&fakeModel{rng: rand.New(rand.NewSource(1)), failAt: -1}}
+	opts := transcribeOptions{Lang: "en", Threads: 1, Filter: defaultFilterConfig()}
+
+	if _, err := newChunkPool(model, 2, opts, []string{""}); err == nil || !strings.Contains(err.Error(), "make patch") {
+		t.Errorf("err = %v, want a request to patch the bindings", err)
+	}
+	pool, err := newChunkPool(model, 1, opts, []string{""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Submit(audioSegment{samples: []float32{0}})
+	if segments, err := pool.Wait(); err != nil || len(segments) != 2 {
+		t.Errorf("got %d segments, %v; want 2", len(segments), err)
+	}
+}
+
+func TestPromptCarry(t *testing.T) {
+	c := newPromptCarry("Glossary: Kyiv, Zaporizhzhia.", 4)
+	if got := c.Prompt(0); got != "Glossary: Kyiv, Zaporizhzhia." {
+		t.Errorf("first prompt = %q", got)
+	}
+
+	c.Add(0, []string{" We", " drove", " to", " Zap", "oriz", "hzh", "ia"})
+	if got, want := c.Prompt(0), "Glossary: Kyiv, Zaporizhzhia. Zaporizhzhia"; got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+	// The last 4 tokens now start mid-word, so the fragment is dropped
+	c.Add(0, []string{" today"})
+	if got, want := c.Prompt(0), "Glossary: Kyiv, Zaporizhzhia. today"; got != want {
+		t.Errorf("prompt = %q, want %q", got, want)
+	}
+
+	// Tracks are independent, and an empty chunk keeps the context
+	if got := c.Prompt(1); got != "Glossary: Kyiv, Zaporizhzhia." {
+		t.Errorf("other track prompt = %q", got)
+	}
+	c.Add(0, nil)
+	if got := c.Prompt(0); !strings.HasSuffix(got, " today") {
+		t.Errorf("prompt after empty chunk = %q", got)
+	}
+
+	// Without a user prompt, only the carried text
+	c = newPromptCarry("", 3)
+	c.Add(0, []string{" one", " two", " three", " four"})
+	if got := c.Prompt(0); got != "two three four" {
+		t.Errorf("prompt = %q, want %q", got, "two three four")
+	}
+}
+
+func TestChunkPoolCarry(t *testing.T) {
+	model := &fakeModel{rng: rand.New(rand.NewSource(1)), failAt: -1}
+	opts := transcribeOptions{Lang: "en", Prompt: "Names:", Threads: 1, Filter: defaultFilterConfig(), CarryTokens: 3}
+	pool, err := newChunkPool(model, 1, opts, []string{"left", "right"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, track := range []int{0, 1, 0} {
+		pool.Submit(audioSegment{samples: []float32{float32(i)}, startSec: float64(i), track: track})
+	}
+	if _, err := pool.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first prompt is set by newChunkContext, then one per chunk
+	want := []string{"Names:", "Names:", "Names:", "Names: of the speech."}
+	if fmt.Sprint(model.prompts) != fmt.Sprint(want) {
+		t.Errorf("prompts = %q, want %q", model.prompts, want)
+	}
+
+	if _, err := newChunkPool(model, 2, opts, []string{""}); err == nil {
+		t.Error("expected error for carrying with two workers")
+	}
+}
+
+// loopContext returns one segment whose text loops.
+type loopContext struct {
+	fakeContext
+	text string
+}
+
+func (c *loopContext) Process(_ []float32, _ whisper.EncoderBeginCallback, cb whisper.SegmentCallback, _ whisper.ProgressCallback) error {
+	tokens := []whisper.Token{{Id: 50365, Text: "[_TT_0]"}}
+	for _, w := range strings.Fields(c.text) {
+		tokens = append(tokens, whisper.Token{Id: len(tokens), Text: " " + w, P: 0.9})
+	}
+	cb(whisper.Segment{End: 2 * time.Second, Text: c.text, Tokens: tokens})
+	return nil
+}
+
+func TestTranscribeChunkWordLoop(t *testing.T) {
+	text := strings.Repeat(" I'm going to go to the store.", 4)
+	ctx := &loopContext{text: text}
+	opts := transcribeOptions{Lang: "en", Filter: defaultFilterConfig()}
+
+	res, err := transcribeChunk(ctx, opts, audioSegment{startSec: 10}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.segments) != 1 || res.segments[0].Text != " I'm going to go to the store." {
+		t.Fatalf("segments = %+v, want the loop cut to one sentence", res.segments)
+	}
+	// Scores are of the text whisper produced, loop included
+	want := opts.Filter.compressionRatio(strings.TrimSpace(text))
+	if got := res.segments[0].CompressionRatio; got != roundScore(want) {
+		t.Errorf("compression ratio = %v, want %v of the original text", got, roundScore(want))
+	}
+	if len(res.dropped) != 1 {
+		t.Fatalf("dropped = %+v, want one word_loop entry", res.dropped)
+	}
+	if d := res.dropped[0]; d.Verdict.Rule != ruleWordLoop || d.Verdict.Value != 4 || d.Start != "00:00:10.000" {
+		t.Errorf("dropped = %+v, want 4 repeats at 00:00:10.000", d)
+	}
+}
+
+// BenchmarkChunkContext measures the per-chunk cost of building a fresh
+// context for every chunk against reusing one, on 300 short chunks of
+// silence, the shape of choppy speech. Needs a model:
+//
+//	WHISPER_MODEL=models/ggml-tiny.bin go test -run '^$' -bench ChunkContext -benchtime 1x
+func BenchmarkChunkContext(b *testing.B) {
+	modelPath := os.Getenv("WHISPER_MODEL")
+	if modelPath == "" {
+		modelPath = "models/ggml-large-v3-turbo.bin"
+	}
+	if _, err := os.Stat(modelPath); os.IsNotExist(err) {
+		b.Skipf("Model not found at %s; set WHISPER_MODEL or run make setup", modelPath)
+	}
+	model, err := whisper.New(modelPath)
+	if err != nil {
+		b.Fatalf("Failed to load model: %v", err)
+	}
+	defer model.Close()
+
+	const numChunks = 300
+	chunk := audioSegment{samples: make([]float32, sampleRate/2)}
+	opts := transcribeOptions{Lang: "en", Threads: runtime.NumCPU(), Filter: defaultFilterConfig()}
+
+	b.Run("per-chunk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for c := 0; c < numChunks; c++ {
+				ctx, err := newChunkContext(model, opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := transcribeChunk(ctx, opts, chunk, ""); err != nil {
+					b.Fatal(err)
+				}
+				closeContext(ctx)
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Milliseconds())/float64(b.N*numChunks), "ms/chunk")
+	})
+	b.Run("reused", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ctx, err := newChunkContext(model, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for c := 0; c < numChunks; c++ {
+				if _, err := transcribeChunk(ctx, opts, chunk, ""); err != nil {
+					b.Fatal(err)
+				}
+			}
+			closeContext(ctx)
+		}
+		b.ReportMetric(float64(b.Elapsed().Milliseconds())/float64(b.N*numChunks), "ms/chunk")
+	})
+}
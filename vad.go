@@ -1,3 +1,5 @@
+//go:build !notenvad
+
 package main
 
 /*
@@ -25,6 +27,9 @@ import (
 	"unsafe"
 )
 
+// Vad is the ten-vad backend: a small neural VAD, accurate on noisy input,
+// that needs the prebuilt native library. Build with -tags notenvad to leave
+// it out; the energy backend is then the only one available.
 type Vad struct {
 	instance C.ten_vad_handle_t
 	hopSize  int
@@ -56,22 +61,22 @@ func (v *Vad) Close() {
 	}
 }
 
-// Process runs VAD on a single frame of int16 PCM samples.
-// Returns speech probability, whether speech was detected, and any error.
-func (v *Vad) Process(frame []int16) (float32, bool, error) {
+// Process runs VAD on a single frame of int16 PCM samples and returns its
+// speech probability.
+func (v *Vad) Process(frame []int16) (float32, error) {
 	if v.instance == nil {
-		return 0, false, fmt.Errorf("vad: instance closed")
+		return 0, fmt.Errorf("vad: instance closed")
 	}
 	if len(frame) != v.hopSize {
-		return 0, false, fmt.Errorf("vad: frame length %d != hop_size %d", len(frame), v.hopSize)
+		return 0, fmt.Errorf("vad: frame length %d != hop_size %d", len(frame), v.hopSize)
 	}
 
 	var prob C.float
-	var flag C.int
+	var flag C.int // unused: the segmenter applies the threshold itself
 
 	ret := C.ten_vad_process(v.instance, (*C.short)(unsafe.Pointer(&frame[0])), C.size_t(v.hopSize), &prob, &flag)
 	if ret != 0 {
-		return 0, false, fmt.Errorf("ten_vad_process failed (code %d)", ret)
+		return 0, fmt.Errorf("ten_vad_process failed (code %d)", ret)
 	}
-	return float32(prob), flag == 1, nil
+	return float32(prob), nil
 }
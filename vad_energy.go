@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// energyVad is a pure-Go VAD based on frame energy and zero-crossing rate.
+// It is far cruder than ten-vad, clean speech over a quiet background is
+// what it handles well, but it needs no native library and is fully
+// deterministic, which makes it the backend for segmentation tests.
+//
+// A frame's loudness is compared with an adaptive noise floor. The floor
+// follows quiet frames quickly and creeps up slowly during loud ones, so
+// speech does not raise it. Frames with a very high zero-crossing rate
+// sound like noise (hiss, fans, rain) rather than voice: they are
+// discounted and the floor follows them quickly too, so steady broadband
+// noise is learned within a fraction of a second.
+type energyVad struct {
+	hopSize    int
+	noiseFloor float64 // dBFS
+}
+
+const (
+	energyFloorInit   = -60.0 // initial noise floor, dBFS
+	energyFloorMin    = -90.0 // floor for digital silence
+	energyGate        = -55.0 // frames quieter than this are never speech, dBFS
+	energyMargin      = 12.0  // dB above the noise floor where the probability is 0.5
+	energySlope       = 3.0   // dB per logistic unit around the margin
+	energyFloorFall   = 0.1   // weight of a quieter or noise-like frame in the floor average
+	energyFloorRise   = 0.01  // dB per frame the floor rises during loud frames
+	energyHighZCR     = 0.4   // crossing rate above which a frame sounds like noise
+	energyNoiseFactor = 0.5   // probability factor for high crossing-rate frames
+)
+
+func newEnergyVad(hopSize int) *energyVad {
+	return &energyVad{hopSize: hopSize, noiseFloor: energyFloorInit}
+}
+
+func (v *energyVad) Close() {}
+
+// Process returns the speech probability of one frame.
+func (v *energyVad) Process(frame []int16) (float32, error) {
+	if len(frame) != v.hopSize {
+		return 0, fmt.Errorf("vad: frame length %d != hop_size %d", len(frame), v.hopSize)
+	}
+	var sum float64
+	crossings := 0
+	for i, s := range frame {
+		x := float64(s) / math.MaxInt16
+		sum += x * x
+		if i > 0 && (s >= 0) != (frame[i-1] >= 0) {
+			crossings++
+		}
+	}
+	db := energyFloorMin
+	if sum > 0 {
+		db = math.Max(10*math.Log10(sum/float64(len(frame))), energyFloorMin)
+	}
+	zcr := float64(crossings) / float64(len(frame)-1)
+
+	noisy := zcr > energyHighZCR
+	if db < v.noiseFloor || noisy {
+		v.noiseFloor += energyFloorFall * (db - v.noiseFloor)
+	} else {
+		v.noiseFloor += energyFloorRise
+	}
+
+	if db < energyGate {
+		return 0, nil
+	}
+	p := 1 / (1 + math.Exp(-(db-v.noiseFloor-energyMargin)/energySlope))
+	if noisy {
+		p *= energyNoiseFactor
+	}
+	return float32(p), nil
+}
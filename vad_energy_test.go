@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// energyFrames runs gen through a fresh energyVad frame by frame and returns
+// the probability of each frame.
+func energyFrames(t *testing.T, frames int, gen func(i int) float64) []float32 {
+	t.Helper()
+	v := newEnergyVad(hopSize)
+	frame := make([]int16, hopSize)
+	probs := make([]float32, frames)
+	for f := range probs {
+		for i := range frame {
+			frame[i] = int16(gen(f*hopSize+i) * math.MaxInt16)
+		}
+		p, err := v.Process(frame)
+		if err != nil {
+			t.Fatal(err)
+		}
+		probs[f] = p
+	}
+	return probs
+}
+
+func tone(freq, amp float64) func(i int) float64 {
+	return func(i int) float64 { return amp * math.Sin(2*math.Pi*freq*float64(i)/sampleRate) }
+}
+
+func TestEnergyVad(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := func(amp float64) func(int) float64 {
+		return func(int) float64 { return amp * (2*rng.Float64() - 1) }
+	}
+
+	tests := []struct {
+		name     string
+		gen      func(i int) float64
+		min, max float32 // bounds on the mean probability of the last 50 frames
+	}{
+		{"digital silence", func(int) float64 { return 0 }, 0, 0.01},
+		{"quiet noise", noise(0.001), 0, 0.01},
+		{"steady hiss is learned", noise(0.05), 0, 0.1},
+		{"voiced tone", tone(220, 0.3), 0.95, 1},
+		{"tone over background", func(i int) float64 {
+			if i < 100*hopSize {
+				return 0.003 * math.Sin(float64(i))
+			}
+			return tone(220, 0.3)(i)
+		}, 0.95, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probs := energyFrames(t, 150, tt.gen)
+			var mean float32
+			for _, p := range probs[100:] {
+				mean += p / 50
+			}
+			if mean < tt.min || mean > tt.max {
+				t.Errorf("mean probability %.3f, want [%.2f, %.2f]", mean, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestEnergyVadFrameSize(t *testing.T) {
+	if _, err := newEnergyVad(hopSize).Process(make([]int16, hopSize-1)); err == nil {
+		t.Error("expected error for short frame")
+	}
+}
@@ -0,0 +1,19 @@
+//go:build notenvad
+
+package main
+
+import "errors"
+
+// Vad stands in for the ten-vad backend in builds without the native
+// library; NewVad always fails, so only the energy backend can be used.
+type Vad struct{}
+
+func NewVad(hopSize int, threshold float32) (*Vad, error) {
+	return nil, errors.New("built without ten-vad (notenvad tag); use -vad energy")
+}
+
+func (v *Vad) Close() {}
+
+func (v *Vad) Process(frame []int16) (float32, error) {
+	return 0, errors.New("built without ten-vad")
+}
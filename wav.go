@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// WAVE format tags (see mmreg.h).
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavDecoder reads RIFF/WAVE files with integer PCM (8/16/24/32-bit) or
+// IEEE float (32/64-bit) samples, including WAVE_FORMAT_EXTENSIBLE headers.
+type wavDecoder struct {
+	r             io.Reader // positioned at the start of the data chunk
+	format        uint16
+	channels      int
+	sampleRate    int
+	bitsPerSample int
+	blockAlign    int
+	raw           []byte
+}
+
+func newWavDecoder(r io.Reader) (*wavDecoder, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, fmt.Errorf("read riff header: %w", err)
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	d := &wavDecoder{}
+	haveFmt := false
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, fmt.Errorf("read chunk header: %w", err)
+		}
+		id := string(hdr[0:4])
+		size := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+
+		switch id {
+		case "fmt ":
+			if err := d.readFmt(r, size); err != nil {
+				return nil, err
+			}
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, errors.New("data chunk before fmt chunk")
+			}
+			// Streaming writers leave the size as 0 or 0xFFFFFFFF; read to EOF then.
+			if size == 0 || size == math.MaxUint32 {
+				d.r = r
+			} else {
+				d.r = io.LimitReader(r, size)
+			}
+			return d, nil
+		default:
+			// Chunks are word-aligned
+			if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+				return nil, fmt.Errorf("skip %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+func (d *wavDecoder) readFmt(r io.Reader, size int64) error {
+	if size < 16 {
+		return fmt.Errorf("fmt chunk too short (%d bytes)", size)
+	}
+	buf := make([]byte, size+size%2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return fmt.Errorf("read fmt chunk: %w", err)
+	}
+
+	d.format = binary.LittleEndian.Uint16(buf[0:2])
+	d.channels = int(binary.LittleEndian.Uint16(buf[2:4]))
+	d.sampleRate = int(binary.LittleEndian.Uint32(buf[4:8]))
+	d.blockAlign = int(binary.LittleEndian.Uint16(buf[12:14]))
+	d.bitsPerSample = int(binary.LittleEndian.Uint16(buf[14:16]))
+
+	if d.format == wavFormatExtensible {
+		// cbSize(2) validBits(2) channelMask(4) subFormat GUID(16); the
+		// first two GUID bytes carry the actual format tag.
+		if size < 40 {
+			return fmt.Errorf("extensible fmt chunk too short (%d bytes)", size)
+		}
+		d.format = binary.LittleEndian.Uint16(buf[24:26])
+	}
+
+	if d.channels == 0 || d.sampleRate == 0 {
+		return fmt.Errorf("invalid fmt: %d channel(s) at %d Hz", d.channels, d.sampleRate)
+	}
+	switch {
+	case d.format == wavFormatPCM && (d.bitsPerSample == 8 || d.bitsPerSample == 16 ||
+		d.bitsPerSample == 24 || d.bitsPerSample == 32):
+	case d.format == wavFormatFloat && (d.bitsPerSample == 32 || d.bitsPerSample == 64):
+	default:
+		return fmt.Errorf("unsupported format 0x%04x with %d bits per sample", d.format, d.bitsPerSample)
+	}
+	if minAlign := d.channels * d.bitsPerSample / 8; d.blockAlign < minAlign {
+		d.blockAlign = minAlign
+	}
+	return nil
+}
+
+func (d *wavDecoder) SampleRate() int { return d.sampleRate }
+func (d *wavDecoder) Channels() int   { return d.channels }
+
+func (d *wavDecoder) Read(buf []float32) (int, error) {
+	frames := len(buf) / d.channels
+	if cap(d.raw) < frames*d.blockAlign {
+		d.raw = make([]byte, frames*d.blockAlign)
+	}
+	raw := d.raw[:frames*d.blockAlign]
+	n, err := io.ReadFull(d.r, raw)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	frames = n / d.blockAlign
+
+	width := d.bitsPerSample / 8
+	out := 0
+	for i := 0; i < frames; i++ {
+		frame := raw[i*d.blockAlign:]
+		for c := 0; c < d.channels; c++ {
+			buf[out] = d.sample(frame[c*width:])
+			out++
+		}
+	}
+	return out, err
+}
+
+// sample converts one little-endian sample to float32 in [-1, 1].
+func (d *wavDecoder) sample(b []byte) float32 {
+	if d.format == wavFormatFloat {
+		if d.bitsPerSample == 64 {
+			return float32(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	switch d.bitsPerSample {
+	case 8:
+		// 8-bit WAV is unsigned
+		return (float32(b[0]) - 128) / 128.0
+	case 16:
+		return float32(int16(binary.LittleEndian.Uint16(b))) / 32768.0
+	case 24:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float32(v) / 8388608.0
+	default:
+		return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648.0
+	}
+}
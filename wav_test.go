@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"testing"
+)
+
+// makeWAV builds a RIFF/WAVE file from interleaved samples in [-1, 1].
+func makeWAV(format uint16, channels, rate, bits int, extensible bool, samples []float64) []byte {
+	var data bytes.Buffer
+	for _, s := range samples {
+		switch {
+		case format == wavFormatFloat && bits == 32:
+			binary.Write(&data, binary.LittleEndian, float32(s))
+		case format == wavFormatFloat && bits == 64:
+			binary.Write(&data, binary.LittleEndian, s)
+		case bits == 8:
+			data.WriteByte(byte(int(math.Round(s*127)) + 128))
+		case bits == 16:
+			binary.Write(&data, binary.LittleEndian, int16(math.Round(s*32767)))
+		case bits == 24:
+			v := int32(math.Round(s * 8388607))
+			data.Write([]byte{byte(v), byte(v >> 8), byte(v >> 16)})
+		case bits == 32:
+			binary.Write(&data, binary.LittleEndian, int32(math.Round(s*2147483647)))
+		}
+	}
+
+	var fmtChunk bytes.Buffer
+	tag := format
+	if extensible {
+		tag = wavFormatExtensible
+	}
+	blockAlign := channels * bits / 8
+	binary.Write(&fmtChunk, binary.LittleEndian, tag)
+	binary.Write(&fmtChunk, binary.LittleEndian, uint16(channels))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint32(rate))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint32(rate*blockAlign))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&fmtChunk, binary.LittleEndian, uint16(bits))
+	if extensible {
+		binary.Write(&fmtChunk, binary.LittleEndian, uint16(22))   // cbSize
+		binary.Write(&fmtChunk, binary.LittleEndian, uint16(bits)) // valid bits
+		binary.Write(&fmtChunk, binary.LittleEndian, uint32(0))    // channel mask
+		guid := []byte{0, 0, 0, 0, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+		binary.LittleEndian.PutUint16(guid, format)
+		fmtChunk.Write(guid)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(4+8+fmtChunk.Len()+8+3+1+8+data.Len()))
+	out.WriteString("WAVE")
+	out.WriteString("fmt ")
+	binary.Write(&out, binary.LittleEndian, uint32(fmtChunk.Len()))
+	out.Write(fmtChunk.Bytes())
+	// An odd-sized chunk the decoder has to skip, including its pad byte
+	out.WriteString("LIST")
+	binary.Write(&out, binary.LittleEndian, uint32(3))
+	out.Write([]byte{'a', 'b', 'c', 0})
+	out.WriteString("data")
+	binary.Write(&out, binary.LittleEndian, uint32(data.Len()))
+	out.Write(data.Bytes())
+	return out.Bytes()
+}
+
+func TestWavDecoder(t *testing.T) {
+	samples := []float64{0, 0.5, -0.5, 0.25, -1, 0.75}
+
+	tests := []struct {
+		name       string
+		format     uint16
+		channels   int
+		bits       int
+		extensible bool
+		tolerance  float64
+	}{
+		{"pcm8 mono", wavFormatPCM, 1, 8, false, 1.0 / 64},
+		{"pcm16 stereo", wavFormatPCM, 2, 16, false, 1.0 / 16384},
+		{"pcm24 mono", wavFormatPCM, 1, 24, false, 1e-6},
+		{"pcm32 stereo", wavFormatPCM, 2, 32, false, 1e-6},
+		{"float32 stereo", wavFormatFloat, 2, 32, false, 1e-7},
+		{"float64 mono", wavFormatFloat, 1, 64, false, 1e-7},
+		{"extensible pcm16", wavFormatPCM, 2, 16, true, 1.0 / 16384},
+		{"extensible float32", wavFormatFloat, 3, 32, true, 1e-7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := makeWAV(tt.format, tt.channels, 22050, tt.bits, tt.extensible, samples)
+			d, err := newAudioDecoder(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("newAudioDecoder: %v", err)
+			}
+			if _, ok := d.(*wavDecoder); !ok {
+				t.Fatalf("got decoder %T, want *wavDecoder", d)
+			}
+			if d.Channels() != tt.channels || d.SampleRate() != 22050 {
+				t.Fatalf("got %d ch @ %d Hz, want %d ch @ 22050 Hz", d.Channels(), d.SampleRate(), tt.channels)
+			}
+
+			var got []float32
+			buf := make([]float32, 4*tt.channels)
+			for {
+				n, err := d.Read(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Read: %v", err)
+				}
+			}
+
+			want := len(samples) / tt.channels * tt.channels
+			if len(got) != want {
+				t.Fatalf("got %d samples, want %d", len(got), want)
+			}
+			for i, v := range got {
+				if math.Abs(float64(v)-samples[i]) > tt.tolerance {
+					t.Errorf("sample %d = %f, want %f", i, v, samples[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWavDecoderRejectsUnsupported(t *testing.T) {
+	raw := makeWAV(wavFormatPCM, 1, 16000, 16, false, []float64{0, 0})
+	binary.LittleEndian.PutUint16(raw[20:], 0x0055) // MPEG Layer 3 in WAV
+	if _, err := newAudioDecoder(bytes.NewReader(raw)); err == nil {
+		t.Fatal("expected error for unsupported WAVE format tag")
+	}
+}
+
+func TestConvertToSamplesWAV(t *testing.T) {
+	// Stereo at 16 kHz: output is the L/R average with no resampling
+	stereo := []float64{0.5, -0.5, 0.2, 0.4, -1, -1}
+	got, err := convertToSamples(bytes.NewReader(makeWAV(wavFormatFloat, 2, 16000, 32, false, stereo)))
+	if err != nil {
+		t.Fatalf("convertToSamples: %v", err)
+	}
+	want := []float32{0, 0.3, -1}
+	if len(got) != len(want) {
+		t.Fatalf("got %d samples, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if math.Abs(float64(got[i]-want[i])) > 1e-6 {
+			t.Errorf("sample %d = %f, want %f", i, got[i], want[i])
+		}
+	}
+
+	// One second at 48 kHz resamples to roughly one second at 16 kHz
+	tone := make([]float64, 48000)
+	for i := range tone {
+		tone[i] = 0.5 * math.Sin(2*math.Pi*440*float64(i)/48000)
+	}
+	got, err = convertToSamples(bytes.NewReader(makeWAV(wavFormatPCM, 1, 48000, 16, false, tone)))
+	if err != nil {
+		t.Fatalf("convertToSamples: %v", err)
+	}
+	if len(got) < 15500 || len(got) > 16500 {
+		t.Errorf("got %d samples after resampling, want ~16000", len(got))
+	}
+}
+
+func TestWavResampleFlush(t *testing.T) {
+	// 44.1 kHz does not divide evenly into 16 kHz. Silence then a tone step
+	// halfway: the step must stay halfway through the output, and the
+	// samples still held in the resampler's filter must come out at the end.
+	for _, frames := range []int{44100, 22050, 4410} {
+		samples := make([]float64, frames)
+		for i := frames / 2; i < frames; i++ {
+			samples[i] = 0.5
+		}
+		got, err := convertToSamples(bytes.NewReader(makeWAV(wavFormatPCM, 1, 44100, 16, false, samples)))
+		if err != nil {
+			t.Fatalf("%d frames: %v", frames, err)
+		}
+		want := frames * 16000 / 44100
+		if len(got) != want {
+			t.Fatalf("%d frames: got %d samples, want %d", frames, len(got), want)
+		}
+		step := 0
+		for step < len(got) && got[step] < 0.25 {
+			step++
+		}
+		if d := step - want/2; d < -1 || d > 1 {
+			t.Errorf("%d frames: step at sample %d, want %d", frames, step, want/2)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+// transcriptWord is one word of a segment with its own timing, from
+// whisper's token timestamps.
+type transcriptWord struct {
+	Word  string `json:"word"`
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// segmentWords rebuilds words from the text tokens of segment (special
+// tokens are dropped with isText). Whisper's tokens are subwords: a token
+// with a leading space starts a new word, any other continues the current
+// one, which also attaches punctuation to the word before it. Timestamps
+// are shifted by offset, the chunk's position in the input.
+func segmentWords(segment whisper.Segment, isText func(whisper.Token) bool, offset time.Duration) []transcriptWord {
+	var words []transcriptWord
+	var text strings.Builder
+	var start, end time.Duration
+	flush := func() {
+		if w := strings.TrimSpace(text.String()); w != "" {
+			words = append(words, transcriptWord{
+				Word:  w,
+				Start: formatDuration(start + offset),
+				End:   formatDuration(end + offset),
+			})
+		}
+		text.Reset()
+	}
+
+	for _, tok := range segment.Tokens {
+		if !isText(tok) {
+			continue
+		}
+		if strings.HasPrefix(tok.Text, " ") || text.Len() == 0 {
+			flush()
+			start = tok.Start
+		}
+		text.WriteString(tok.Text)
+		end = tok.End
+	}
+	flush()
+	return words
+}
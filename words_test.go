@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	whisper "github.com/ggerganov/whisper.cpp/bindings/go/pkg/whisper"
+)
+
+func TestSegmentWords(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	tok := func(text string, start, end int) whisper.Token {
+		return whisper.Token{Id: 1, Text: text, Start: ms(start), End: ms(end)}
+	}
+	special := whisper.Token{Id: 50365, Text: "[_TT_0]"}
+	isText := (&fakeContext{}).IsText
+
+	tests := []struct {
+		name   string
+		tokens []whisper.Token
+		offset time.Duration
+		want   []transcriptWord
+	}{
+		{
+			name:   "one token per word",
+			tokens: []whisper.Token{special, tok(" Hello", 0, 400), tok(" world", 400, 900)},
+			want: []transcriptWord{
+				{Word: "Hello", Start: "00:00:00.000", End: "00:00:00.400"},
+				{Word: "world", Start: "00:00:00.400", End: "00:00:00.900"},
+			},
+		},
+		{
+			name:   "subwords and punctuation join the word",
+			tokens: []whisper.Token{tok(" Trans", 100, 300), tok("cri", 300, 500), tok("ption", 500, 700), tok(".", 700, 750)},
+			want:   []transcriptWord{{Word: "Transcription.", Start: "00:00:00.100", End: "00:00:00.750"}},
+		},
+		{
+			name:   "first token without leading space",
+			tokens: []whisper.Token{tok("Hi", 0, 200), tok(",", 200, 250), tok(" there", 300, 600)},
+			want: []transcriptWord{
+				{Word: "Hi,", Start: "00:00:00.000", End: "00:00:00.250"},
+				{Word: "there", Start: "00:00:00.300", End: "00:00:00.600"},
+			},
+		},
+		{
+			name:   "chunk offset applied",
+			tokens: []whisper.Token{tok(" late", 200, 500)},
+			offset: 90 * time.Second,
+			want:   []transcriptWord{{Word: "late", Start: "00:01:30.200", End: "00:01:30.500"}},
+		},
+		{
+			name:   "no text tokens",
+			tokens: []whisper.Token{special, tok(" ", 0, 10)},
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := segmentWords(whisper.Segment{Tokens: tt.tokens}, isText, tt.offset)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("segmentWords() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}